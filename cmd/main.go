@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"subscription/internal/config"
 	"subscription/internal/logger"
+	"subscription/internal/pkg/tenant"
 	"subscription/internal/repository"
 	"subscription/internal/server"
 	"subscription/internal/service"
 	"syscall"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 func waitSignal() {
@@ -16,6 +21,56 @@ func waitSignal() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 }
+func importExchangeRates(svc service.Service, lg *zap.SugaredLogger, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		lg.Errorf("failed to open exchange rates: %v", err)
+		return
+	}
+	defer file.Close()
+	imported, err := svc.ImportExchangeRates(context.Background(), file)
+	if err != nil {
+		lg.Errorf("failed to import exchange rates: %v", err)
+		return
+	}
+	lg.Infof("exchange rates imported (%d)", imported)
+}
+func runPurge(ctx context.Context, svc service.Service, lg *zap.SugaredLogger, interval, retention time.Duration) {
+	// the records of every tenant are purged
+	ctx = tenant.NewContext(ctx, tenant.All)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		subscriptions, services, err := svc.PurgeDeleted(ctx, retention)
+		if err != nil {
+			lg.Errorf("failed to purge deleted records: %v", err)
+		} else if subscriptions > 0 || services > 0 {
+			lg.Infof("deleted records purged (subscriptions: %d, services: %d)", subscriptions, services)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+func runIdempotencyCleanup(ctx context.Context, svc service.Service, lg *zap.SugaredLogger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		removed, err := svc.RemoveExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			lg.Errorf("failed to remove expired idempotency keys: %v", err)
+		} else if removed > 0 {
+			lg.Infof("expired idempotency keys removed (%d)", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 func main() {
 	cfg := config.MustNew()
 
@@ -25,9 +80,19 @@ func main() {
 	repo := repository.MustNew(lg, &cfg.Db)
 	defer repo.Close()
 
-	svc := service.New(repo, lg)
+	svc := service.New(repo, lg, cfg.Db.QueryTimeout)
+	if cfg.Db.ExchangeRatesPath != "" {
+		importExchangeRates(svc, lg, cfg.Db.ExchangeRatesPath)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runIdempotencyCleanup(ctx, svc, lg, cfg.Srv.IdempotencyCleanupInterval)
+	if cfg.Db.PurgeAfterDays > 0 {
+		go runPurge(ctx, svc, lg, cfg.Db.PurgeInterval, time.Duration(cfg.Db.PurgeAfterDays)*24*time.Hour)
+	}
 
-	srv := server.New(svc, lg, &cfg.Srv)
+	srv := server.New(service.NewPolicy(svc, lg, cfg.Srv.AdminRole, cfg.Srv.PlatformRole), lg, &cfg.Srv)
 	srv.Start()
 	defer srv.Stop()
 
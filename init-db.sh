@@ -14,4 +14,12 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" <<-EOSQL
         LC_COLLATE = 'ru_RU.UTF-8'
         LC_CTYPE = 'ru_RU.UTF-8'
         TEMPLATE = template0;
-EOSQL
\ No newline at end of file
+EOSQL
+
+# Роль сервиса: не суперпользователь и без BYPASSRLS, чтобы действовали политики
+# row level security. Права на таблицы выдает миграция 000013_app_role
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" \
+    -v app_user="$APP_DB_USER" -v app_password="$APP_DB_PASSWORD" <<-EOSQL
+    CREATE ROLE subscription_app NOLOGIN NOSUPERUSER NOBYPASSRLS;
+    CREATE ROLE :"app_user" LOGIN NOSUPERUSER NOBYPASSRLS PASSWORD :'app_password' IN ROLE subscription_app;
+EOSQL
@@ -22,6 +22,22 @@ type Srv struct {
 	Addr         string        `envconfig:"SRV_ADDR" required:"true"`
 	WriteTimeout time.Duration `envconfig:"SRV_WRITE_TIMEOUT" required:"true"`
 	AppName      string        `envconfig:"SRV_APPNAME" required:"true"`
+
+	IdempotencyTTL             time.Duration `envconfig:"SRV_IDEMPOTENCY_TTL" default:"24h"`
+	IdempotencyCleanupInterval time.Duration `envconfig:"SRV_IDEMPOTENCY_CLEANUP_INTERVAL" default:"1h"`
+
+	AuthDisabled bool          `envconfig:"SRV_AUTH_DISABLED" default:"false"`
+	JWTSecret    string        `envconfig:"SRV_JWT_SECRET"`
+	JWKSPath     string        `envconfig:"SRV_JWKS_PATH"`
+	JWTIssuer    string        `envconfig:"SRV_JWT_ISSUER"`
+	JWTAudience  string        `envconfig:"SRV_JWT_AUDIENCE"`
+	JWTLeeway    time.Duration `envconfig:"SRV_JWT_LEEWAY" default:"1m"`
+	AdminRole    string        `envconfig:"SRV_ADMIN_ROLE" default:"admin"`
+	// PlatformRole imports the exchange rates shared by the tenants
+	PlatformRole string `envconfig:"SRV_PLATFORM_ROLE" default:"platform-admin"`
+	// DefaultTenant is the tenant of requests without X-Tenant-ID when
+	// authentication is disabled
+	DefaultTenant string `envconfig:"SRV_DEFAULT_TENANT" default:"default"`
 }
 
 type Db struct {
@@ -32,6 +48,20 @@ type Db struct {
 	Password      string `envconfig:"DB_PASSWORD" required:"true"`
 	SSLMode       string `envconfig:"DB_SSL_MODE" default:"disable"`
 	MigrationPath string `envconfig:"DB_MIGRATIONS_PATH" required:"true"`
+	// MigrationUser owns the tables and runs the migrations, User is used when
+	// it is not set
+	MigrationUser     string `envconfig:"DB_MIGRATION_USER"`
+	MigrationPassword string `envconfig:"DB_MIGRATION_PASSWORD"`
+
+	MaxConns          int32         `envconfig:"DB_POOL_MAX_CONNS" default:"10"`
+	MinConns          int32         `envconfig:"DB_POOL_MIN_CONNS" default:"0"`
+	MaxConnIdleTime   time.Duration `envconfig:"DB_POOL_MAX_CONN_IDLE_TIME" default:"30m"`
+	MaxConnLifetime   time.Duration `envconfig:"DB_POOL_MAX_CONN_LIFETIME" default:"1h"`
+	HealthCheckPeriod time.Duration `envconfig:"DB_POOL_HEALTH_CHECK_PERIOD" default:"1m"`
+	QueryTimeout      time.Duration `envconfig:"DB_QUERY_TIMEOUT" default:"5s"`
+	ExchangeRatesPath string        `envconfig:"DB_EXCHANGE_RATES_PATH"`
+	PurgeAfterDays    int           `envconfig:"DB_PURGE_AFTER_DAYS" default:"0"`
+	PurgeInterval     time.Duration `envconfig:"DB_PURGE_INTERVAL" default:"1h"`
 }
 
 func MustNew() *Config {
@@ -1,21 +1,218 @@
 package model
 
 import (
+	"encoding/json"
 	"subscription/internal/pkg/types"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Service struct {
-	ServiceId int    `json:"service_id"`
-	Name      string `json:"name"`
+	ServiceId int        `json:"service_id"`
+	Name      string     `json:"name"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int64      `json:"version"`
 }
 
 type Subscription struct {
 	SubscriptionId int               `json:"subscription_id"`
 	ServiceId      int               `json:"service_id"`
 	Price          int               `json:"price"`
+	Currency       string            `json:"currency"`
 	UserId         uuid.UUID         `json:"user_id"`
 	StartDate      types.CustomDate  `json:"start_date"`
 	StopDate       *types.CustomDate `json:"stop_date"`
+	BillingPeriod  string            `json:"billing_period"`
+	DeletedAt      *time.Time        `json:"deleted_at,omitempty"`
+	Version        int64             `json:"version"`
+}
+
+// ExportedSubscription is a row of a subscription export.
+type ExportedSubscription struct {
+	Subscription
+	ServiceName string `json:"service_name"`
+}
+
+// SubscriptionCursor reads the rows of an export one at a time as they arrive
+// from the database. The same value is returned by Subscription for every
+// row, so it must not be kept after Next is called again.
+type SubscriptionCursor interface {
+	Next() bool
+	Subscription() *ExportedSubscription
+	Err() error
+	Close()
+}
+
+const (
+	ExportFormatCSV       = "csv"
+	ExportFormatJSONLines = "jsonl"
+)
+
+type SubscriptionPrice struct {
+	EffectiveDate types.CustomDate `json:"effective_date"`
+	Price         int              `json:"price"`
+}
+
+const DefaultCurrency = "RUB"
+
+type ExchangeRate struct {
+	BaseCurrency  string           `json:"base_currency"`
+	QuoteCurrency string           `json:"quote_currency"`
+	EffectiveDate types.CustomDate `json:"effective_date"`
+	Rate          float64          `json:"rate"`
+}
+
+const (
+	BillingPeriodWeekly    = "weekly"
+	BillingPeriodMonthly   = "monthly"
+	BillingPeriodQuarterly = "quarterly"
+	BillingPeriodYearly    = "yearly"
+)
+
+type PoolStats struct {
+	MaxConns                int32 `json:"max_conns"`
+	TotalConns              int32 `json:"total_conns"`
+	AcquiredConns           int32 `json:"acquired_conns"`
+	IdleConns               int32 `json:"idle_conns"`
+	ConstructingConns       int32 `json:"constructing_conns"`
+	AcquireCount            int64 `json:"acquire_count"`
+	AcquireDuration         int64 `json:"acquire_duration_ms"`
+	EmptyAcquireCount       int64 `json:"empty_acquire_count"`
+	EmptyAcquireWaitTime    int64 `json:"empty_acquire_wait_time_ms"`
+	CanceledAcquireCount    int64 `json:"canceled_acquire_count"`
+	NewConnsCount           int64 `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64 `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64 `json:"max_idle_destroy_count"`
+}
+
+type AuditEntry struct {
+	AuditId   int64           `json:"audit_id"`
+	ChangedAt time.Time       `json:"changed_at"`
+	Actor     string          `json:"actor"`
+	Operation string          `json:"operation"`
+	Entity    string          `json:"entity"`
+	EntityId  int             `json:"entity_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+}
+
+type AuditList struct {
+	Items      []*AuditEntry `json:"items"`
+	Total      int           `json:"total"`
+	Offset     int           `json:"offset"`
+	Limit      int           `json:"limit"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+}
+
+const (
+	BatchOperationCreate = "create"
+	BatchOperationUpdate = "update"
+	BatchOperationDelete = "delete"
+)
+
+// BatchResult is the outcome of the operation at Index of a batch request,
+// Subscription is set for a created subscription.
+type BatchResult struct {
+	Index        int
+	Operation    string
+	Subscription *Subscription
+	Err          error
+}
+
+const (
+	ImportLineValid    = "valid"
+	ImportLineImported = "imported"
+	ImportLineInvalid  = "invalid"
+)
+
+// ImportReport describes every data line of an imported file, the lines are
+// numbered as in the file.
+type ImportReport struct {
+	DryRun          bool          `json:"dry_run"`
+	Total           int           `json:"total"`
+	Valid           int           `json:"valid"`
+	Invalid         int           `json:"invalid"`
+	CreatedServices []string      `json:"created_services"`
+	Lines           []*ImportLine `json:"lines"`
+}
+type ImportLine struct {
+	Line           int    `json:"line"`
+	Status         string `json:"status"`
+	SubscriptionId int    `json:"subscription_id,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// APIKey describes a key of a machine client, the key itself is only known
+// when it is created or rotated.
+type APIKey struct {
+	APIKeyId   int64      `json:"api_key_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	TenantId   string     `json:"-"`
+}
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+}
+
+type ServiceList struct {
+	Items      []*Service `json:"items"`
+	Total      int        `json:"total"`
+	Offset     int        `json:"offset"`
+	Limit      int        `json:"limit"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+}
+
+type SubscriptionList struct {
+	Items      []*Subscription `json:"items"`
+	Total      int             `json:"total"`
+	Offset     int             `json:"offset"`
+	Limit      int             `json:"limit"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+}
+
+type SubscriptionTotalRow struct {
+	Period      types.CustomDate `json:"period"`
+	ServiceId   *int             `json:"service_id,omitempty"`
+	ServiceName *string          `json:"service_name,omitempty"`
+	UserId      *uuid.UUID       `json:"user_id,omitempty"`
+	Count       int              `json:"count"`
+	Amount      int              `json:"amount"`
+}
+
+type SubscriptionTotal struct {
+	Total     int                     `json:"total"`
+	Currency  string                  `json:"currency"`
+	Breakdown []*SubscriptionTotalRow `json:"breakdown"`
+}
+
+type DataRevision struct {
+	Revision   int64
+	ModifiedAt time.Time
+}
+
+const (
+	HealthStatusOk          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+type Health struct {
+	Status string     `json:"status"`
+	Pool   *PoolStats `json:"pool"`
 }
@@ -0,0 +1,19 @@
+package actor
+
+import "context"
+
+// Unknown is recorded when a change is made without an identified actor.
+const Unknown = "unknown"
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+func FromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(contextKey{}).(string); ok && name != "" {
+		return name
+	}
+	return Unknown
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ScopeReadServices       = "read:services"
+	ScopeWriteServices      = "write:services"
+	ScopeReadSubscriptions  = "read:subscriptions"
+	ScopeWriteSubscriptions = "write:subscriptions"
+	ScopeReports            = "reports"
+)
+
+// Principal is the authenticated caller of a request acting in Tenant. A
+// caller authenticated with an API key is limited to the scopes of the key
+// instead of roles.
+type Principal struct {
+	Subject   string
+	Tenant    string
+	Roles     []string
+	APIKey    bool
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal of ctx or nil for a request that was not
+// authenticated.
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(contextKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,34 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrorInvalid = errors.New("invalid cursor")
+
+// Cursor points at a row of an ordered list. Values holds the sort key of
+// the row, Backward tells whether the page precedes or follows it.
+type Cursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func Encode(c *Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func Decode(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrorInvalid
+	}
+	c := new(Cursor)
+	if err := json.Unmarshal(b, c); err != nil || len(c.Values) == 0 {
+		return nil, ErrorInvalid
+	}
+	return c, nil
+}
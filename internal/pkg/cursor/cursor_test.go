@@ -0,0 +1,48 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []*Cursor{
+		{Sort: "subscription_id", Values: []string{"42"}},
+		{Sort: "-price,subscription_id", Values: []string{"400", "7"}, Backward: true},
+		{Sort: "stop_date,subscription_id", Values: []string{"infinity", "3"}},
+		{Sort: "service_name,service_id", Values: []string{"Яндекс Плюс & \"co\"/?", "1"}},
+	}
+	for _, c := range tests {
+		s := Encode(c)
+		got, err := Decode(s)
+		if err != nil {
+			t.Fatalf("%q: got error %v", s, err)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Fatalf("got %+v, want %+v", got, c)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	tests := map[string]string{
+		"empty":              "",
+		"not base64":         "!!!",
+		"padded base64":      base64.URLEncoding.EncodeToString([]byte(`{"s":"a","v":["1"]}`)),
+		"standard base64":    base64.RawStdEncoding.EncodeToString([]byte(`{"s":"a?","v":["1>"]}`)),
+		"not JSON":           encode("42"),
+		"no values":          encode(`{"s":"subscription_id"}`),
+		"empty values":       encode(`{"s":"subscription_id","v":[]}`),
+		"values not strings": encode(`{"s":"subscription_id","v":[42]}`),
+	}
+	for name, s := range tests {
+		if _, err := Decode(s); !errors.Is(err, ErrorInvalid) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrorInvalid)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+type publicKey struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS reads the RSA and P-256 signature keys of a JSON Web Key Set,
+// keys of other types are ignored.
+func parseJWKS(data []byte) ([]*publicKey, error) {
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse key set: %w", err)
+	}
+	keys := []*publicKey{}
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			alg string
+			err error
+		)
+		switch {
+		case jwk.Kty == "RSA":
+			key, err = rsaKey(jwk)
+			alg = algRS256
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			key, err = ecKey(jwk)
+			alg = algES256
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		if jwk.Alg != "" && jwk.Alg != alg {
+			continue
+		}
+		keys = append(keys, &publicKey{id: jwk.Kid, alg: alg, key: key})
+	}
+	return keys, nil
+}
+
+func rsaKey(jwk *jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid RSA modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	exponent := new(big.Int).SetBytes(e)
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func ecKey(jwk *jsonWebKey) (*ecdsa.PublicKey, error) {
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid EC point")
+	}
+	point := append([]byte{4}, append(x, y...)...)
+	return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+}
@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// minSecretLength is the shortest HS256 secret accepted, shorter secrets can
+// be guessed to forge tokens.
+const minSecretLength = 32
+
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+	algES256 = "ES256"
+)
+
+var algorithms = []string{algHS256, algRS256, algES256}
+
+var (
+	ErrorMalformed            = errors.New("malformed token")
+	ErrorUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrorUnknownKey           = errors.New("unknown signing key")
+	ErrorInvalidSignature     = errors.New("invalid signature")
+	ErrorExpired              = errors.New("token has expired")
+	ErrorNotValidYet          = errors.New("token is not valid yet")
+	ErrorInvalidClaims        = errors.New("invalid claims")
+)
+
+// Claims are the claims of a verified token used by the service.
+type Claims struct {
+	Subject   string
+	Tenant    string
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+type Config struct {
+	// Secret verifies HS256 tokens.
+	Secret []byte
+	// JWKS is a JSON Web Key Set with the keys verifying RS256 and ES256 tokens.
+	JWKS []byte
+	// Issuer and Audience are checked when they are set.
+	Issuer   string
+	Audience string
+	// Leeway is allowed for the clock skew in the checks of exp and nbf.
+	Leeway time.Duration
+}
+
+type Verifier struct {
+	secret []byte
+	keys   []*publicKey
+	parser *gojwt.Parser
+}
+
+func NewVerifier(cfg *Config) (*Verifier, error) {
+	v := &Verifier{secret: cfg.Secret}
+	if len(v.secret) > 0 && len(v.secret) < minSecretLength {
+		return nil, fmt.Errorf("secret is shorter than %d bytes", minSecretLength)
+	}
+	if len(cfg.JWKS) > 0 {
+		keys, err := parseJWKS(cfg.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	if len(v.secret) == 0 && len(v.keys) == 0 {
+		return nil, errors.New("no secret or keys to verify tokens")
+	}
+	options := []gojwt.ParserOption{
+		gojwt.WithValidMethods(algorithms),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, gojwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, gojwt.WithAudience(cfg.Audience))
+	}
+	v.parser = gojwt.NewParser(options...)
+	return v, nil
+}
+
+type claims struct {
+	gojwt.RegisteredClaims
+	Roles  []string `json:"roles"`
+	Tenant string   `json:"tenant_id"`
+}
+
+// Verify checks the signature and the claims of a compact serialized token.
+// The token must name a subject and expire.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	var c claims
+	parsed, err := v.parser.ParseWithClaims(token, &c, v.key)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrorUnknownKey):
+		return nil, ErrorUnknownKey
+	case errors.Is(err, ErrorUnsupportedAlgorithm), errors.Is(err, gojwt.ErrTokenUnverifiable),
+		parsed != nil && parsed.Method != nil && !slices.Contains(algorithms, parsed.Method.Alg()):
+		return nil, ErrorUnsupportedAlgorithm
+	case errors.Is(err, gojwt.ErrTokenMalformed):
+		return nil, ErrorMalformed
+	case errors.Is(err, gojwt.ErrTokenSignatureInvalid):
+		return nil, ErrorInvalidSignature
+	case errors.Is(err, gojwt.ErrTokenExpired):
+		return nil, ErrorExpired
+	case errors.Is(err, gojwt.ErrTokenNotValidYet):
+		return nil, ErrorNotValidYet
+	default:
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidClaims, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrorInvalidClaims)
+	}
+	return &Claims{
+		Subject:   c.Subject,
+		Tenant:    c.Tenant,
+		Roles:     c.Roles,
+		ExpiresAt: c.ExpiresAt.Time,
+	}, nil
+}
+
+// key returns the secret for HS256 and the keys of the algorithm otherwise,
+// the key named by kid when it is given.
+func (v *Verifier) key(token *gojwt.Token) (any, error) {
+	alg := token.Method.Alg()
+	if alg == algHS256 {
+		if len(v.secret) == 0 {
+			return nil, ErrorUnsupportedAlgorithm
+		}
+		return v.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	set := gojwt.VerificationKeySet{}
+	for _, key := range v.keys {
+		if key.alg == alg && (kid == "" || key.id == kid) {
+			set.Keys = append(set.Keys, key.key)
+		}
+	}
+	if len(set.Keys) == 0 {
+		return nil, ErrorUnknownKey
+	}
+	return set, nil
+}
@@ -0,0 +1,280 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+const leeway = 30 * time.Second
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	jwks []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	ecPoint, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{
+			"kid": "rsa-1", "kty": "RSA", "use": "sig",
+			"n": encode(rsaKey.N.Bytes()),
+			"e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kid": "ec-1", "kty": "EC", "crv": "P-256",
+			"x": encode(ecPoint[1:33]),
+			"y": encode(ecPoint[33:]),
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rsaKey, ec: ecKey, jwks: jwks}
+}
+
+func sign(t *testing.T, method gojwt.SigningMethod, kid string, key any, claims gojwt.MapClaims) string {
+	t.Helper()
+	token := gojwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func claimsAt(now time.Time, extra gojwt.MapClaims) gojwt.MapClaims {
+	c := gojwt.MapClaims{
+		"sub":       "user-1",
+		"tenant_id": "acme",
+		"roles":     []string{"admin"},
+		"aud":       "subscription",
+		"exp":       now.Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// withSignature replaces the signature of a token by the given bytes.
+func withSignature(token string, signature []byte) string {
+	i := strings.LastIndexByte(token, '.')
+	return token[:i+1] + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	now := time.Now()
+	rsaPublic, err := json.Marshal(keys.rsa.PublicKey.N.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	es256 := sign(t, gojwt.SigningMethodES256, "ec-1", keys.ec, claimsAt(now, nil))
+
+	tests := []struct {
+		name     string
+		noSecret bool
+		token    string
+		err      error
+	}{
+		{
+			name:  "valid HS256",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, nil)),
+		},
+		{
+			name:  "valid RS256",
+			token: sign(t, gojwt.SigningMethodRS256, "rsa-1", keys.rsa, claimsAt(now, nil)),
+		},
+		{
+			name:  "valid RS256 without kid",
+			token: sign(t, gojwt.SigningMethodRS256, "", keys.rsa, claimsAt(now, nil)),
+		},
+		{
+			name:  "valid ES256",
+			token: es256,
+		},
+		{
+			name:  "alg none",
+			token: sign(t, gojwt.SigningMethodNone, "", gojwt.UnsafeAllowNoneSignatureType, claimsAt(now, nil)),
+			err:   ErrorUnsupportedAlgorithm,
+		},
+		{
+			name:  "unsupported HS512",
+			token: sign(t, gojwt.SigningMethodHS512, "", secret, claimsAt(now, nil)),
+			err:   ErrorUnsupportedAlgorithm,
+		},
+		{
+			name:     "HS256 signed with the RSA public key without a secret",
+			noSecret: true,
+			token:    sign(t, gojwt.SigningMethodHS256, "rsa-1", rsaPublic, claimsAt(now, nil)),
+			err:      ErrorUnsupportedAlgorithm,
+		},
+		{
+			name:  "HS256 signed with the RSA public key",
+			token: sign(t, gojwt.SigningMethodHS256, "rsa-1", rsaPublic, claimsAt(now, nil)),
+			err:   ErrorInvalidSignature,
+		},
+		{
+			name:  "RS256 header with an HMAC signature",
+			token: withSignature(sign(t, gojwt.SigningMethodRS256, "rsa-1", keys.rsa, claimsAt(now, nil)), make([]byte, 32)),
+			err:   ErrorInvalidSignature,
+		},
+		{
+			name:  "unknown kid",
+			token: sign(t, gojwt.SigningMethodRS256, "rsa-2", keys.rsa, claimsAt(now, nil)),
+			err:   ErrorUnknownKey,
+		},
+		{
+			name:  "kid of a key of another algorithm",
+			token: sign(t, gojwt.SigningMethodRS256, "ec-1", keys.rsa, claimsAt(now, nil)),
+			err:   ErrorUnknownKey,
+		},
+		{
+			name:  "expired within leeway",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"exp": now.Add(-leeway + 2*time.Second).Unix()})),
+		},
+		{
+			name:  "expired beyond leeway",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"exp": now.Add(-leeway - 2*time.Second).Unix()})),
+			err:   ErrorExpired,
+		},
+		{
+			name:  "not valid yet within leeway",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"nbf": now.Add(leeway - 2*time.Second).Unix()})),
+		},
+		{
+			name:  "not valid yet beyond leeway",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"nbf": now.Add(leeway + 2*time.Second).Unix()})),
+			err:   ErrorNotValidYet,
+		},
+		{
+			name:  "missing exp",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"exp": nil})),
+			err:   ErrorInvalidClaims,
+		},
+		{
+			name:  "missing sub",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"sub": nil})),
+			err:   ErrorInvalidClaims,
+		},
+		{
+			name:  "array aud with the audience",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"aud": []string{"other", "subscription"}})),
+		},
+		{
+			name:  "array aud without the audience",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"aud": []string{"other", "billing"}})),
+			err:   ErrorInvalidClaims,
+		},
+		{
+			name:  "wrong aud",
+			token: sign(t, gojwt.SigningMethodHS256, "", secret, claimsAt(now, gojwt.MapClaims{"aud": "other"})),
+			err:   ErrorInvalidClaims,
+		},
+		{
+			name:  "ES256 signature of 63 bytes",
+			token: withSignature(es256, make([]byte, 63)),
+			err:   ErrorInvalidSignature,
+		},
+		{
+			name:  "ES256 signature of 65 bytes",
+			token: withSignature(es256, make([]byte, 65)),
+			err:   ErrorInvalidSignature,
+		},
+		{
+			name:  "empty ES256 signature",
+			token: withSignature(es256, nil),
+			err:   ErrorInvalidSignature,
+		},
+		{
+			name:  "ES256 signature with zero r and s",
+			token: withSignature(es256, make([]byte, 64)),
+			err:   ErrorInvalidSignature,
+		},
+		{
+			name:  "malformed token",
+			token: "not.a-token",
+			err:   ErrorMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{JWKS: keys.jwks, Audience: "subscription", Leeway: leeway}
+			if !tt.noSecret {
+				cfg.Secret = secret
+			}
+			v, err := NewVerifier(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := v.Verify(tt.token)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Tenant != "acme" || len(claims.Roles) != 1 {
+				t.Fatalf("unexpected claims: %+v", claims)
+			}
+		})
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		ok   bool
+	}{
+		{name: "secret", cfg: &Config{Secret: secret}, ok: true},
+		{name: "short secret", cfg: &Config{Secret: secret[:31]}},
+		{name: "no secret or keys", cfg: &Config{}},
+		{name: "invalid key set", cfg: &Config{JWKS: []byte("{")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVerifier(tt.cfg)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var ErrorInvalid = errors.New("invalid merge patch")
+
+// Apply applies a JSON Merge Patch (RFC 7396) to the document: members of
+// a patch object replace the members of the document, null removes them and
+// nested objects are merged recursively. A patch that is not an object
+// replaces the document.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var d, p any
+	if err := decode(doc, &d); err != nil {
+		return nil, err
+	}
+	if err := decode(patch, &p); err != nil {
+		return nil, ErrorInvalid
+	}
+	return json.Marshal(merge(d, p))
+}
+
+func decode(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return ErrorInvalid
+	}
+	return nil
+}
+
+func merge(doc, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]any)
+	if !ok {
+		d = map[string]any{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(d, key)
+			continue
+		}
+		d[key] = merge(d[key], value)
+	}
+	return d
+}
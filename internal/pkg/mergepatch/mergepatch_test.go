@@ -0,0 +1,53 @@
+package mergepatch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// the examples of RFC 7396 appendix A, the results have sorted keys
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"remove one of members", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"replace array with value", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"replace value with array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"merge nested object", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"replace array of objects", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"replace array", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"replace object with array", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"replace object with null", `{"a":"foo"}`, `null`, `null`},
+		{"replace object with string", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"keep null in patch array", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{"patch array", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"create nested object", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"keep number", `{"price":100}`, `{"price":1e2}`, `{"price":1e2}`},
+		{"empty patch", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyInvalidPatch(t *testing.T) {
+	for _, patch := range []string{``, `{`, `{"a":1}{"b":2}`, `{"a":1} x`, `nul`} {
+		if _, err := Apply([]byte(`{"a":"b"}`), []byte(patch)); !errors.Is(err, ErrorInvalid) {
+			t.Errorf("patch %q: got error %v, want %v", patch, err, ErrorInvalid)
+		}
+	}
+}
@@ -1,8 +1,81 @@
 package servererrors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
-	ErrorRecordNotFound = errors.New("record not found")
-	ErrorInternal       = errors.New("internal server error")
+	ErrorRecordNotFound  = errors.New("record not found")
+	ErrorInternal        = errors.New("internal server error")
+	ErrorCanceled        = errors.New("request canceled")
+	ErrorTimeout         = errors.New("query timeout")
+	ErrorServiceNotFound = errors.New("service not found")
+	ErrorServiceExists   = errors.New("service already exists")
+	ErrorServiceInUse    = errors.New("service has active subscriptions")
+	ErrorInvalidPeriod   = errors.New("start date is after stop date")
+	ErrorPeriodTooLong   = errors.New("period is too long")
+	ErrorInvalidCursor   = errors.New("invalid cursor")
+	ErrorVersionMismatch = errors.New("record has been modified")
+
+	ErrorExchangeRateNotFound = errors.New("exchange rate not found")
+
+	ErrorUnauthenticated = errors.New("authentication required")
+	ErrorInvalidToken    = errors.New("invalid token")
+	ErrorInvalidAPIKey   = errors.New("invalid api key")
+	ErrorForbidden       = errors.New("access denied")
+	ErrorInvalidTenant   = errors.New("invalid tenant")
+
+	ErrorIdempotencyKeyReused     = errors.New("idempotency key reused with another request")
+	ErrorIdempotencyKeyInProgress = errors.New("request with the idempotency key is in progress")
 )
+
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// PeriodError is an invalid period of a request reported for the field
+// that ends it.
+type PeriodError struct {
+	Field string
+	Err   error
+}
+
+func (e *PeriodError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+func (e *PeriodError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is the error of the operation at Index of a batch request.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchErrors collects the errors of several operations of a batch request.
+type BatchErrors []*BatchError
+
+func (e BatchErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
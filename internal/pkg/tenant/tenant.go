@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// All lets background jobs reach the rows of every tenant, it is never a
+// valid tenant of a request.
+const All = "*"
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// Valid reports whether id may name a tenant.
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant of ctx or an empty string, which matches no
+// row, when ctx has none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
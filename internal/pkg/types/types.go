@@ -16,13 +16,28 @@ const (
 	DbFormat         = "2006-01-02"
 )
 
+// ParseCustomDate accepts MM-YYYY (the first day of the month), ISO dates
+// and RFC 3339 timestamps, of which only the date part is kept.
+func ParseCustomDate(s string) (CustomDate, error) {
+	for _, layout := range []string{CustomDateFormat, DbFormat} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return CustomDate{Time: t}, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return CustomDate{}, fmt.Errorf("date %q must be in MM-YYYY, YYYY-MM-DD or RFC 3339 format", s)
+	}
+	return CustomDate{Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}, nil
+}
+
 func (cd *CustomDate) UnmarshalJSON(b []byte) error {
 	s := strings.Trim(string(b), `"`)
-	t, err := time.Parse(CustomDateFormat, s)
+	d, err := ParseCustomDate(s)
 	if err != nil {
 		return err
 	}
-	cd.Time = t
+	*cd = d
 
 	return nil
 }
@@ -30,8 +45,17 @@ func (cd CustomDate) MarshalJSON() ([]byte, error) {
 	if cd.Time.IsZero() {
 		return []byte("null"), nil
 	}
-	return fmt.Appendf(nil, `"%s"`, cd.Time.Format(CustomDateFormat)), nil
+	return fmt.Appendf(nil, `"%s"`, cd.String()), nil
+
+}
 
+// String formats the first day of a month as MM-YYYY to stay compatible with
+// month precision dates and any other day as YYYY-MM-DD.
+func (cd CustomDate) String() string {
+	if cd.Day() == 1 {
+		return cd.Format(CustomDateFormat)
+	}
+	return cd.Format(DbFormat)
 }
 
 func (cd *CustomDate) Scan(value interface{}) error {
@@ -44,17 +68,17 @@ func (cd *CustomDate) Scan(value interface{}) error {
 	case time.Time:
 		cd.Time = v
 	case string:
-		t, err := time.Parse(CustomDateFormat, v)
+		d, err := ParseCustomDate(v)
 		if err != nil {
 			return err
 		}
-		cd.Time = t
+		*cd = d
 	case []byte:
-		t, err := time.Parse(CustomDateFormat, string(v))
+		d, err := ParseCustomDate(string(v))
 		if err != nil {
 			return err
 		}
-		cd.Time = t
+		*cd = d
 	default:
 		return fmt.Errorf("unsupported type: %T", value)
 	}
@@ -1,6 +1,11 @@
 package validator
 
 import (
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
 	"github.com/go-playground/validator"
 )
 
@@ -12,6 +17,9 @@ func init() {
 
 func New() *validator.Validate {
 	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	_ = v.RegisterValidation("sort", validateSort)
+	_ = v.RegisterValidation("currency", validateCurrency)
 	return v
 }
 func SetValidator(v *validator.Validate) {
@@ -23,3 +31,39 @@ func Validator() *validator.Validate {
 func Validate(structure any) error {
 	return Validator().Struct(structure)
 }
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "params", "query"} {
+		name := strings.SplitN(field.Tag.Get(key), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validateSort checks a comma separated list of sort fields, each optionally
+// prefixed with "-" for descending order, against the space separated
+// whitelist given as the tag parameter: `validate:"sort=name price"`.
+func validateSort(fl validator.FieldLevel) bool {
+	allowed := strings.Fields(fl.Param())
+	seen := map[string]bool{}
+	for _, field := range strings.Split(fl.Field().String(), ",") {
+		field = strings.TrimPrefix(field, "-")
+		if seen[field] || !slices.Contains(allowed, field) {
+			return false
+		}
+		seen[field] = true
+	}
+	return true
+}
+
+var currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// validateCurrency checks an ISO 4217 alphabetic currency code.
+func validateCurrency(fl validator.FieldLevel) bool {
+	return currencyRegexp.MatchString(fl.Field().String())
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	"subscription/internal/repository/dto"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	apiKeyColumns  = `api_key_id,name,prefix,scopes,created_at,expires_at,revoked_at,last_used_at,tenant_id`
+	addAPIKeyQuery = `
+INSERT INTO api_keys (name,prefix,key_hash,scopes,expires_at,tenant_id) VALUES ($1,$2,$3,$4,$5,$6) 
+RETURNING ` + apiKeyColumns
+	getAPIKeysQuery   = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE tenant_id=$1 ORDER BY api_key_id`
+	rotateAPIKeyQuery = `
+UPDATE api_keys SET prefix=$2,key_hash=$3 WHERE api_key_id=$1 AND tenant_id=$4 AND revoked_at IS null 
+RETURNING ` + apiKeyColumns
+	revokeAPIKeyQuery = `UPDATE api_keys SET revoked_at=now() WHERE api_key_id=$1 AND tenant_id=$2 AND revoked_at IS null`
+	// the key is looked up in every tenant as the tenant of the request is
+	// the one of the key; the time of the last use is only written once a
+	// minute to spare the writes of busy clients
+	authenticateAPIKeyQuery = `
+WITH k AS (
+	SELECT ` + apiKeyColumns + ` FROM api_keys 
+	WHERE key_hash=$1 AND revoked_at IS null AND (expires_at IS null OR expires_at>now())
+), used AS (
+	UPDATE api_keys SET last_used_at=now() 
+	WHERE api_key_id=(SELECT api_key_id FROM k) AND (last_used_at IS null OR last_used_at<now()-interval '1 minute')
+)
+SELECT ` + apiKeyColumns + ` FROM k`
+)
+
+func apiKeyFields(key *model.APIKey) []any {
+	return []any{
+		&key.APIKeyId,
+		&key.Name,
+		&key.Prefix,
+		&key.Scopes,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+		&key.LastUsedAt,
+		&key.TenantId,
+	}
+}
+
+func (r *repository) AddAPIKey(ctx context.Context, dto *dto.AddAPIKey) (*model.APIKey, error) {
+	key := new(model.APIKey)
+	err := r.pool.QueryRow(ctx, addAPIKeyQuery, dto.Name, dto.Prefix, dto.KeyHash, dto.Scopes, dto.ExpiresAt, tenant.FromContext(ctx)).Scan(apiKeyFields(key)...)
+	if err != nil {
+		return nil, r.queryError(err, "failed to add api key")
+	}
+	return key, nil
+}
+func (r *repository) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	rows, err := r.pool.Query(ctx, getAPIKeysQuery, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, r.queryError(err, "failed to get api keys")
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*model.APIKey, error) {
+		key := new(model.APIKey)
+		return key, row.Scan(apiKeyFields(key)...)
+	})
+	if err != nil {
+		return nil, r.queryError(err, "failed to get api keys")
+	}
+	return keys, nil
+}
+
+// RotateAPIKey replaces the hash of a key that is not revoked, the previous
+// key stops working at once.
+func (r *repository) RotateAPIKey(ctx context.Context, dto *dto.RotateAPIKey) (*model.APIKey, error) {
+	key := new(model.APIKey)
+	err := r.pool.QueryRow(ctx, rotateAPIKeyQuery, dto.APIKeyId, dto.Prefix, dto.KeyHash, tenant.FromContext(ctx)).Scan(apiKeyFields(key)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, servererrors.ErrorRecordNotFound
+	}
+	if err != nil {
+		return nil, r.queryError(err, "failed to rotate api key")
+	}
+	return key, nil
+}
+func (r *repository) RevokeAPIKey(ctx context.Context, apiKeyId int64) error {
+	result, err := r.pool.Exec(ctx, revokeAPIKeyQuery, apiKeyId, tenant.FromContext(ctx))
+	if err != nil {
+		return r.queryError(err, "failed to revoke api key")
+	}
+	if result.RowsAffected() == 0 {
+		return servererrors.ErrorRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the active key with the hash and records its use.
+func (r *repository) AuthenticateAPIKey(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	key := new(model.APIKey)
+	err := r.pool.QueryRow(ctx, authenticateAPIKeyQuery, keyHash).Scan(apiKeyFields(key)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, servererrors.ErrorRecordNotFound
+	}
+	if err != nil {
+		return nil, r.queryError(err, "failed to authenticate api key")
+	}
+	return key, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	"subscription/internal/repository/dto"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// errNoRowChanged stops a batch when an update or a remove matched no row,
+// the cause is resolved by notChanged afterwards.
+var errNoRowChanged = errors.New("no row changed")
+
+// BatchSubscriptions runs ops in one transaction. An atomic batch stops at the
+// first failed operation and returns its *servererrors.BatchError, otherwise
+// the error of a failed operation is set on its result and the others are
+// kept.
+func (r *repository) BatchSubscriptions(ctx context.Context, ops []*dto.SubscriptionOperation, atomic bool) ([]*model.BatchResult, error) {
+
+	results := newBatchResults(ops)
+	if atomic {
+		err := r.inTx(ctx, func(tx pgx.Tx) error {
+			return sendSubscriptionOperations(ctx, tx, ops, results)
+		})
+		var batchErr *servererrors.BatchError
+		if errors.As(err, &batchErr) {
+			return nil, &servererrors.BatchError{Index: batchErr.Index, Err: r.queryError(batchErr.Err, "failed to run batch operation")}
+		}
+		if err != nil {
+			return nil, r.queryError(err, "failed to run batch")
+		}
+		return results, nil
+	}
+
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		return r.runSubscriptionOperations(ctx, tx, ops, results)
+	})
+	if err != nil {
+		return nil, r.queryError(err, "failed to run batch")
+	}
+	return results, nil
+}
+
+func newBatchResults(ops []*dto.SubscriptionOperation) []*model.BatchResult {
+	results := make([]*model.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = &model.BatchResult{Index: op.Index, Operation: operationName(op)}
+	}
+	return results
+}
+
+// runSubscriptionOperations runs the operations in savepoints and sets the
+// error of a failed one on its result. Only a canceled or timed out query
+// fails the whole run. The operations are sent together until one fails, the
+// ones before it were rolled back with it and are sent again, then the ones
+// after it. Operations whose result has an error already are skipped.
+func (r *repository) runSubscriptionOperations(ctx context.Context, tx pgx.Tx, ops []*dto.SubscriptionOperation, results []*model.BatchResult) error {
+	lo, hi := 0, len(ops)
+	for lo < len(ops) {
+		pendingOps, pendingResults := []*dto.SubscriptionOperation{}, []*model.BatchResult{}
+		for i := lo; i < hi; i++ {
+			if results[i].Err == nil {
+				pendingOps = append(pendingOps, ops[i])
+				pendingResults = append(pendingResults, results[i])
+			}
+		}
+		if len(pendingOps) > 0 {
+			err := pgx.BeginFunc(ctx, tx, func(sp pgx.Tx) error {
+				return sendSubscriptionOperations(ctx, sp, pendingOps, pendingResults)
+			})
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+				return err
+			}
+			var batchErr *servererrors.BatchError
+			if err != nil && !errors.As(err, &batchErr) {
+				return err
+			}
+			if batchErr != nil {
+				failed := slices.IndexFunc(ops, func(op *dto.SubscriptionOperation) bool { return op.Index == batchErr.Index })
+				results[failed].Subscription = nil
+				results[failed].Err = r.queryError(batchErr.Err, "failed to run batch operation")
+				hi = failed
+				continue
+			}
+		}
+		lo, hi = hi, len(ops)
+	}
+	return nil
+}
+
+func operationName(op *dto.SubscriptionOperation) string {
+	switch {
+	case op.Create != nil:
+		return model.BatchOperationCreate
+	case op.Update != nil:
+		return model.BatchOperationUpdate
+	}
+	return model.BatchOperationDelete
+}
+
+// sendSubscriptionOperations sends ops to the database in one round trip and
+// returns the error of the first failed operation as *servererrors.BatchError.
+func sendSubscriptionOperations(ctx context.Context, tx pgx.Tx, ops []*dto.SubscriptionOperation, results []*model.BatchResult) error {
+
+	var (
+		batch    pgx.Batch
+		done     int
+		tenantId = tenant.FromContext(ctx)
+	)
+	changed := func(ct pgconn.CommandTag) error {
+		if ct.RowsAffected() == 0 {
+			return errNoRowChanged
+		}
+		done++
+		return nil
+	}
+	for i, op := range ops {
+		switch {
+		case op.Create != nil:
+			subscription := new(model.Subscription)
+			results[i].Subscription = subscription
+			batch.Queue(addSubscriptionQuery, addSubscriptionArgs(tenantId, op.Create)...).QueryRow(func(row pgx.Row) error {
+				if err := row.Scan(subscriptionFields(subscription)...); err != nil {
+					return err
+				}
+				done++
+				return nil
+			})
+		case op.Update != nil:
+			batch.Queue(addSubscriptionPriceQuery, addSubscriptionPriceArgs(tenantId, op.Update)...)
+			batch.Queue(updateSubscriptionQuery, updateSubscriptionArgs(tenantId, op.Update)...).Exec(changed)
+		default:
+			batch.Queue(removeSubscriptionQuery, op.Remove.SubscriptionId, op.Remove.Versions, tenantId).Exec(changed)
+		}
+	}
+	err := tx.SendBatch(ctx, &batch).Close()
+	if err == nil || done >= len(ops) {
+		return err
+	}
+	op := ops[done]
+	if errors.Is(err, errNoRowChanged) {
+		id := 0
+		if op.Update != nil {
+			id = op.Update.SubscriptionId
+		} else {
+			id = op.Remove.SubscriptionId
+		}
+		err = notChanged(ctx, tx, subscriptionExistsQuery, id)
+	}
+	return &servererrors.BatchError{Index: op.Index, Err: err}
+}
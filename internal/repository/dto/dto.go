@@ -2,39 +2,161 @@ package dto
 
 import (
 	"subscription/internal/pkg/types"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// Versions of the change requests hold the versions the record is expected
+// to have, the record is changed regardless of its version when nil.
 type UpdateService struct {
 	ServiceId int
 	Name      string
+	Versions  []int64
+}
+
+type RemoveService struct {
+	ServiceId int
+	Versions  []int64
+}
+
+type GetService struct {
+	ServiceId      int
+	IncludeDeleted bool
 }
 
 type AddSubscription struct {
-	ServiceName string
-	Price       int
-	UserId      uuid.UUID
-	StartDate   types.CustomDate
-	StopDate    *types.CustomDate
+	ServiceName   string
+	Price         int
+	Currency      string
+	UserId        uuid.UUID
+	StartDate     types.CustomDate
+	StopDate      *types.CustomDate
+	BillingPeriod string
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+type Keyset struct {
+	Values   []string
+	Backward bool
+}
+
+type GetServices struct {
+	IncludeDeleted bool
+	Sort           []SortField
+	Keyset         *Keyset
+	Offset         int
+	Limit          int
+}
+
+type GetSubscription struct {
+	SubscriptionId int
+	IncludeDeleted bool
+}
+
+type SubscriptionFilter struct {
+	UserId         *uuid.UUID
+	ServiceId      *int
+	ServiceName    *string
+	Search         *string
+	PriceMin       *int
+	PriceMax       *int
+	ActiveOn       *types.CustomDate
+	StartFrom      *types.CustomDate
+	StartTo        *types.CustomDate
+	StopFrom       *types.CustomDate
+	StopTo         *types.CustomDate
+	OpenEnded      bool
+	IncludeDeleted bool
 }
 
 type GetSubscriptions struct {
-	Offset *int
-	Limit  *int
+	Filter SubscriptionFilter
+	Sort   []SortField
+	Keyset *Keyset
+	Offset int
+	Limit  int
+}
+type ExportSubscriptions struct {
+	Filter SubscriptionFilter
+	Sort   []SortField
 }
 type GetSubscriptionTotal struct {
-	StartDate   types.CustomDate
-	StopDate    types.CustomDate
-	UserId      *uuid.UUID
-	ServiceName *string
+	StartDate      types.CustomDate
+	StopDate       types.CustomDate
+	UserId         *uuid.UUID
+	ServiceName    *string
+	GroupByService bool
+	GroupByUser    bool
+	Prorate        bool
+	Currency       string
+	IncludeDeleted bool
 }
 
 type UpdateSubscription struct {
+	SubscriptionId     int
+	ServiceName        string
+	Price              int
+	PriceEffectiveDate *types.CustomDate
+	UserId             uuid.UUID
+	StartDate          types.CustomDate
+	StopDate           *types.CustomDate
+	BillingPeriod      *string
+	Currency           *string
+	Versions           []int64
+}
+
+type RemoveSubscription struct {
 	SubscriptionId int
-	ServiceName    string
-	Price          int
-	UserId         uuid.UUID
-	StartDate      types.CustomDate
-	StopDate       *types.CustomDate
+	Versions       []int64
+}
+
+// SubscriptionOperation is an operation of a batch request at Index, exactly
+// one of Create, Update and Remove is set.
+type SubscriptionOperation struct {
+	Index  int
+	Create *AddSubscription
+	Update *UpdateSubscription
+	Remove *RemoveSubscription
+}
+
+// ImportSubscriptions holds the create operations of an import.
+type ImportSubscriptions struct {
+	Operations     []*SubscriptionOperation
+	CreateServices bool
+	DryRun         bool
+}
+
+type AddAPIKey struct {
+	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+type RotateAPIKey struct {
+	APIKeyId int64
+	Prefix   string
+	KeyHash  string
+}
+
+type AuditFilter struct {
+	Entity    *string
+	EntityId  *int
+	Actor     *string
+	Operation *string
+	From      *types.CustomDate
+	To        *types.CustomDate
+}
+
+type GetAuditLog struct {
+	Filter AuditFilter
+	Sort   []SortField
+	Keyset *Keyset
+	Offset int
+	Limit  int
 }
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"subscription/internal/model"
+	"subscription/internal/pkg/tenant"
+	"subscription/internal/repository/dto"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const exportSubscriptionsQuery = `
+SELECT ` + subscriptionColumns + `,(SELECT sv."name" FROM services sv WHERE sv.service_id=subscriptions.service_id) 
+FROM subscriptions`
+
+type subscriptionCursor struct {
+	r       *repository
+	rows    pgx.Rows
+	current model.ExportedSubscription
+	err     error
+}
+
+// ExportSubscriptions starts the query of the export, the rows are read by
+// the cursor returned which holds a connection until it is closed.
+func (r *repository) ExportSubscriptions(ctx context.Context, dto *dto.ExportSubscriptions) (model.SubscriptionCursor, error) {
+	if err := checkSort(subscriptionSortColumns, dto.Sort, "subscription_id"); err != nil {
+		return nil, r.queryError(err, "failed to export subscriptions")
+	}
+	b := subscriptionFilter(tenant.FromContext(ctx), &dto.Filter)
+	query := exportSubscriptionsQuery + b.whereClause() + orderBy(subscriptionSortColumns, dto.Sort, false)
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, r.queryError(err, "failed to export subscriptions")
+	}
+	return &subscriptionCursor{r: r, rows: rows}, nil
+}
+
+func (c *subscriptionCursor) Next() bool {
+	if c.err != nil || !c.rows.Next() {
+		return false
+	}
+	c.current = model.ExportedSubscription{}
+	fields := append(subscriptionFields(&c.current.Subscription), &c.current.ServiceName)
+	if err := c.rows.Scan(fields...); err != nil {
+		c.err = c.r.queryError(err, "failed to export subscriptions")
+		c.rows.Close()
+		return false
+	}
+	return true
+}
+func (c *subscriptionCursor) Subscription() *model.ExportedSubscription {
+	return &c.current
+}
+func (c *subscriptionCursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.rows.Err(); err != nil {
+		return c.r.queryError(err, "failed to export subscriptions")
+	}
+	return nil
+}
+func (c *subscriptionCursor) Close() {
+	c.rows.Close()
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/repository/dto"
+)
+
+var errInvalidSort = errors.New("invalid sort")
+
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+var subscriptionSortColumns = map[string]sortColumn{
+	"subscription_id": {"subscription_id", "bigint"},
+	"service_id":      {"service_id", "bigint"},
+	"price":           {"price", "integer"},
+	"user_id":         {"user_id", "uuid"},
+	"start_date":      {"start_date", "date"},
+	"stop_date":       {"COALESCE(stop_date,'infinity'::date)", "date"},
+}
+
+var serviceSortColumns = map[string]sortColumn{
+	"service_id": {"service_id", "bigint"},
+}
+
+var auditSortColumns = map[string]sortColumn{
+	"audit_id": {"audit_id", "bigint"},
+}
+
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// arg registers a query argument and returns its placeholder.
+func (b *queryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// where adds a condition; every %s in cond is replaced with the placeholder
+// of the matching value.
+func (b *queryBuilder) where(cond string, values ...any) {
+	placeholders := make([]any, len(values))
+	for i, value := range values {
+		placeholders[i] = b.arg(value)
+	}
+	b.conditions = append(b.conditions, fmt.Sprintf(cond, placeholders...))
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func subscriptionFilter(tenantId string, f *dto.SubscriptionFilter) *queryBuilder {
+	b := new(queryBuilder)
+	b.where("tenant_id=%s", tenantId)
+	if !f.IncludeDeleted {
+		b.where("deleted_at IS null")
+	}
+	if f.UserId != nil {
+		b.where("user_id=%s", *f.UserId)
+	}
+	if f.ServiceId != nil {
+		b.where("service_id=%s", *f.ServiceId)
+	}
+	if f.ServiceName != nil {
+		b.where(`service_id IN (SELECT service_id FROM services WHERE tenant_id=%s AND "name"=%s)`, tenantId, *f.ServiceName)
+	}
+	if f.Search != nil {
+		b.where(`service_id IN (SELECT service_id FROM services WHERE tenant_id=%s AND "name" ILIKE '%%' || %s || '%%')`, tenantId, escapeLike(*f.Search))
+	}
+	if f.PriceMin != nil {
+		b.where("price>=%s", *f.PriceMin)
+	}
+	if f.PriceMax != nil {
+		b.where("price<=%s", *f.PriceMax)
+	}
+	if f.ActiveOn != nil {
+		b.where("start_date<=%[1]s AND (stop_date IS NULL OR stop_date>=%[1]s)", *f.ActiveOn)
+	}
+	if f.StartFrom != nil {
+		b.where("start_date>=%s", *f.StartFrom)
+	}
+	if f.StartTo != nil {
+		b.where("start_date<=%s", *f.StartTo)
+	}
+	if f.StopFrom != nil {
+		b.where("stop_date>=%s", *f.StopFrom)
+	}
+	if f.StopTo != nil {
+		b.where("stop_date<=%s", *f.StopTo)
+	}
+	if f.OpenEnded {
+		b.where("stop_date IS NULL")
+	}
+	return b
+}
+
+func auditFilter(tenantId string, f *dto.AuditFilter) *queryBuilder {
+	b := new(queryBuilder)
+	b.where("tenant_id=%s", tenantId)
+	if f.Entity != nil {
+		b.where("entity=%s", *f.Entity)
+	}
+	if f.EntityId != nil {
+		b.where("entity_id=%s", *f.EntityId)
+	}
+	if f.Actor != nil {
+		b.where("actor=%s", *f.Actor)
+	}
+	if f.Operation != nil {
+		b.where("operation=%s", *f.Operation)
+	}
+	if f.From != nil {
+		b.where("changed_at>=%s::date", *f.From)
+	}
+	if f.To != nil {
+		b.where("changed_at<%s::date+1", *f.To)
+	}
+	return b
+}
+
+// orderBy builds the ORDER BY clause; backward reverses every direction so
+// that the rows preceding a keyset can be fetched nearest first.
+func orderBy(columns map[string]sortColumn, sort []dto.SortField, backward bool) string {
+	terms := make([]string, 0, len(sort))
+	for _, field := range sort {
+		direction := "ASC"
+		if field.Desc != backward {
+			direction = "DESC"
+		}
+		terms = append(terms, columns[field.Field].expr+" "+direction)
+	}
+	return " ORDER BY " + strings.Join(terms, ",")
+}
+
+// keyset adds the condition selecting rows strictly after (or before, when
+// backward) the row with the given sort key values:
+// (a > $1) OR (a = $1 AND b > $2) OR ...
+func (b *queryBuilder) keyset(columns map[string]sortColumn, sort []dto.SortField, keyset *dto.Keyset) error {
+	if len(keyset.Values) != len(sort) {
+		return servererrors.ErrorInvalidCursor
+	}
+	placeholders := make([]string, len(sort))
+	for i, field := range sort {
+		placeholders[i] = b.arg(keyset.Values[i]) + "::" + columns[field.Field].cast
+	}
+	terms := make([]string, 0, len(sort))
+	for i, field := range sort {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, columns[sort[j].Field].expr+"="+placeholders[j])
+		}
+		op := ">"
+		if field.Desc != keyset.Backward {
+			op = "<"
+		}
+		parts = append(parts, columns[field.Field].expr+op+placeholders[i])
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	b.conditions = append(b.conditions, "("+strings.Join(terms, " OR ")+")")
+	return nil
+}
+
+// checkSort makes sure every sort field is whitelisted and the list ends
+// with a unique key, which keyset pagination relies on.
+func checkSort(columns map[string]sortColumn, sort []dto.SortField, key string) error {
+	if len(sort) == 0 || sort[len(sort)-1].Field != key {
+		return errInvalidSort
+	}
+	for _, field := range sort {
+		if _, ok := columns[field.Field]; !ok {
+			return errInvalidSort
+		}
+	}
+	return nil
+}
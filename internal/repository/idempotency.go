@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"subscription/internal/model"
+	"subscription/internal/pkg/actor"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	removeExpiredIdempotencyKeysQuery = `DELETE FROM idempotency_keys WHERE expires_at<=now()`
+	// an expired key is claimed again, as is the key of the same request
+	// whose lease ran out before it completed
+	claimIdempotencyKeyQuery = `
+INSERT INTO idempotency_keys AS k (actor,idempotency_key,fingerprint,expires_at,locked_until,tenant_id) 
+VALUES ($1,$2,$3,now()+$4::interval,now()+$5::interval,$6) 
+ON CONFLICT (tenant_id,actor,idempotency_key) DO UPDATE 
+SET fingerprint=EXCLUDED.fingerprint,expires_at=EXCLUDED.expires_at,locked_until=EXCLUDED.locked_until,
+status=null,content_type=null,headers=null,body=null,created_at=now()
+WHERE k.expires_at<=now() OR (k.status IS null AND k.locked_until<=now() AND k.fingerprint=EXCLUDED.fingerprint)`
+	getIdempotencyKeyQuery = `
+SELECT fingerprint,status,content_type,headers,body FROM idempotency_keys WHERE actor=$1 AND idempotency_key=$2 AND tenant_id=$3`
+	completeIdempotencyKeyQuery = `
+UPDATE idempotency_keys SET status=$3,content_type=$4,headers=$5,body=$6 
+WHERE actor=$1 AND idempotency_key=$2 AND tenant_id=$7 AND status IS null`
+	removeIdempotencyKeyQuery = `
+DELETE FROM idempotency_keys WHERE actor=$1 AND idempotency_key=$2 AND tenant_id=$3 AND status IS null`
+)
+
+// ClaimIdempotencyKey stores a new key of the actor of ctx and returns nil
+// or returns the response stored for a key already used. Keys are scoped to
+// the actor and the tenant. The key is held for the lease while the request
+// is handled.
+func (r *repository) ClaimIdempotencyKey(ctx context.Context, key string, fingerprint string, ttl time.Duration, lease time.Duration) (*model.IdempotentResponse, error) {
+	name, tenantId := actor.FromContext(ctx), tenant.FromContext(ctx)
+	var resp *model.IdempotentResponse
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, claimIdempotencyKeyQuery, name, key, fingerprint, ttl, lease, tenantId)
+		if err != nil || result.RowsAffected() == 1 {
+			return err
+		}
+
+		var storedFingerprint string
+		var status *int
+		var contentType *string
+		var headers map[string]string
+		var body []byte
+		err = tx.QueryRow(ctx, getIdempotencyKeyQuery, name, key, tenantId).Scan(&storedFingerprint, &status, &contentType, &headers, &body)
+		if errors.Is(err, sql.ErrNoRows) {
+			return servererrors.ErrorIdempotencyKeyInProgress
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case storedFingerprint != fingerprint:
+			return servererrors.ErrorIdempotencyKeyReused
+		case status == nil:
+			return servererrors.ErrorIdempotencyKeyInProgress
+		}
+		resp = &model.IdempotentResponse{
+			Status:  *status,
+			Headers: headers,
+			Body:    body,
+		}
+		if contentType != nil {
+			resp.ContentType = *contentType
+		}
+		return nil
+	})
+	if errors.Is(err, servererrors.ErrorIdempotencyKeyReused) || errors.Is(err, servererrors.ErrorIdempotencyKeyInProgress) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, r.queryError(err, "failed to claim idempotency key")
+	}
+	return resp, nil
+}
+func (r *repository) CompleteIdempotencyKey(ctx context.Context, key string, resp *model.IdempotentResponse) error {
+	_, err := r.pool.Exec(ctx, completeIdempotencyKeyQuery, actor.FromContext(ctx), key, resp.Status, resp.ContentType, resp.Headers, resp.Body, tenant.FromContext(ctx))
+	if err != nil {
+		return r.queryError(err, "failed to complete idempotency key")
+	}
+	return nil
+}
+func (r *repository) RemoveIdempotencyKey(ctx context.Context, key string) error {
+	_, err := r.pool.Exec(ctx, removeIdempotencyKeyQuery, actor.FromContext(ctx), key, tenant.FromContext(ctx))
+	if err != nil {
+		return r.queryError(err, "failed to remove idempotency key")
+	}
+	return nil
+}
+
+// RemoveExpiredIdempotencyKeys deletes the expired keys of every tenant.
+func (r *repository) RemoveExpiredIdempotencyKeys(ctx context.Context) (int, error) {
+	result, err := r.pool.Exec(ctx, removeExpiredIdempotencyKeysQuery)
+	if err != nil {
+		return 0, r.queryError(err, "failed to remove expired idempotency keys")
+	}
+	return int(result.RowsAffected()), nil
+}
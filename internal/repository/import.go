@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	"subscription/internal/repository/dto"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	addMissingServicesQuery = `
+INSERT INTO services (name,tenant_id) SELECT DISTINCT unnest($1::text[]),$2::character varying 
+ON CONFLICT (tenant_id,name) WHERE deleted_at IS null DO NOTHING 
+RETURNING name`
+	getServiceNamesQuery = `
+SELECT name FROM services WHERE name=ANY($1) AND tenant_id=$2 AND deleted_at IS null`
+)
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
+// ImportSubscriptions adds the subscriptions of an import in one transaction
+// and returns the result of every one and the names of the services created.
+// A failed subscription is skipped, a dry run is rolled back at the end.
+func (r *repository) ImportSubscriptions(ctx context.Context, dto *dto.ImportSubscriptions) ([]*model.BatchResult, []string, error) {
+
+	results := newBatchResults(dto.Operations)
+	created := []string{}
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		names := make([]string, 0, len(dto.Operations))
+		for _, op := range dto.Operations {
+			names = append(names, op.Create.ServiceName)
+		}
+		if dto.CreateServices {
+			rows, err := tx.Query(ctx, addMissingServicesQuery, names, tenant.FromContext(ctx))
+			if err != nil {
+				return err
+			}
+			created, err = pgx.CollectRows(rows, pgx.RowTo[string])
+			if err != nil {
+				return err
+			}
+		} else {
+			// the lines of unknown services fail before the run so that they
+			// don't interrupt it
+			rows, err := tx.Query(ctx, getServiceNamesQuery, names, tenant.FromContext(ctx))
+			if err != nil {
+				return err
+			}
+			known, err := pgx.CollectRows(rows, pgx.RowTo[string])
+			if err != nil {
+				return err
+			}
+			exists := make(map[string]bool, len(known))
+			for _, name := range known {
+				exists[name] = true
+			}
+			for i, op := range dto.Operations {
+				if !exists[op.Create.ServiceName] {
+					results[i].Err = servererrors.ErrorServiceNotFound
+				}
+			}
+		}
+		if err := r.runSubscriptionOperations(ctx, tx, dto.Operations, results); err != nil {
+			return err
+		}
+		if dto.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, nil, r.queryError(err, "failed to import subscriptions")
+	}
+	return results, created, nil
+}
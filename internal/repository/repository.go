@@ -5,97 +5,329 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"subscription/internal/config"
 	"subscription/internal/model"
+	"subscription/internal/pkg/actor"
 	"subscription/internal/pkg/migration"
 	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
 	"subscription/internal/repository/dto"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
 )
 
 const (
-	addServiceQuery    = `INSERT INTO services (name) VALUES ($1) RETURNING *`
-	getServiceQuery    = `SELECT service_id,name FROM services WHERE service_id=$1`
-	getServicesQuery   = `SELECT service_id,name FROM services`
-	updateServiceQuery = `UPDATE services SET name=$2 WHERE service_id=$1`
-	removeServiceQuery = `DELETE FROM services WHERE service_id=$1`
+	serviceColumns     = `service_id,name,deleted_at,version`
+	addServiceQuery    = `INSERT INTO services (name,tenant_id) VALUES ($1,$2) RETURNING ` + serviceColumns
+	getServiceQuery    = `SELECT ` + serviceColumns + ` FROM services WHERE service_id=$1 AND tenant_id=$3 AND ($2 OR deleted_at IS null)`
+	getServicesQuery   = `SELECT ` + serviceColumns + ` FROM services`
+	countServicesQuery = `SELECT count(*) FROM services`
+	updateServiceQuery = `
+UPDATE services SET name=$2 
+WHERE service_id=$1 AND tenant_id=$4 AND deleted_at IS null AND ($3::bigint[] IS null OR version=ANY($3))`
+	serviceExistsQuery = `SELECT EXISTS (SELECT 1 FROM services WHERE service_id=$1 AND tenant_id=$2 AND deleted_at IS null)`
+	// the service row is locked so that no subscription is added while it is
+	// removed, the subscriptions added and moved to the service share the lock.
+	// The subscriptions are checked by the next statement, whose snapshot
+	// includes the subscriptions committed while waiting for the lock
+	lockServiceQuery = `
+SELECT version FROM services WHERE service_id=$1 AND tenant_id=$2 AND deleted_at IS null FOR UPDATE`
+	serviceInUseQuery = `
+SELECT EXISTS (SELECT 1 FROM subscriptions WHERE service_id=$1 AND tenant_id=$2 AND deleted_at IS null)`
+	removeServiceQuery  = `UPDATE services SET deleted_at=now() WHERE service_id=$1 AND tenant_id=$2`
+	restoreServiceQuery = `UPDATE services SET deleted_at=null WHERE service_id=$1 AND tenant_id=$2`
 
+	subscriptionColumns  = `subscription_id,service_id,price,currency,user_id,start_date,stop_date,billing_period,deleted_at,version`
 	addSubscriptionQuery = `
-INSERT INTO subscriptions (service_id,price,user_id,start_date,stop_date) 
-VALUES ((SELECT service_id FROM services WHERE "name"=$1),$2,$3,$4,$5) 
-RETURNING subscription_id,service_id,price,user_id,start_date,stop_date`
+WITH s AS (
+	INSERT INTO subscriptions (service_id,price,currency,user_id,start_date,stop_date,billing_period,tenant_id) 
+	VALUES ((SELECT service_id FROM services WHERE "name"=$1 AND tenant_id=$8 AND deleted_at IS null FOR SHARE),$2,$3,$4,$5,$6,$7,$8) 
+	RETURNING ` + subscriptionColumns + `
+), p AS (
+	INSERT INTO subscription_prices (subscription_id,effective_date,price,tenant_id) 
+	SELECT subscription_id,start_date,price,$8 FROM s
+)
+SELECT ` + subscriptionColumns + ` FROM s`
 	getSubscriptionQuery = `
-SELECT subscription_id,service_id,price,user_id,start_date,stop_date 
-FROM subscriptions WHERE subscription_id=$1`
+SELECT ` + subscriptionColumns + ` 
+FROM subscriptions WHERE subscription_id=$1 AND tenant_id=$3 AND ($2 OR deleted_at IS null)`
 	getSubscriptionsQuery = `
-SELECT subscription_id,service_id,price,user_id,start_date,stop_date 
-FROM subscriptions OFFSET COALESCE($1,0) LIMIT COALESCE($2,10)`
+SELECT ` + subscriptionColumns + ` 
+FROM subscriptions`
+	countSubscriptionsQuery = `SELECT count(*) FROM subscriptions`
+	// every month of the period is returned, the months without subscriptions
+	// with zero totals
 	getSubscriptionTotalQuery = `
-WITH t AS (
+WITH months AS (
+	SELECT generate_series(date_trunc('month',$1::date),date_trunc('month',$2::date),interval '1 month')::date AS period
+), items AS (
+SELECT
+	m.period,
+	s.service_id,
+	sv.name,
+	s.user_id,
+	p.price*r.rate*c.active_days/c.cycle_days AS prorated,
+	p.price*r.rate*c.charges AS charged,
+	r.rate
+FROM months m
+JOIN subscriptions s ON s.start_date<=(m.period+interval '1 month')::date-1 AND (s.stop_date IS null OR s.stop_date>=m.period)
+JOIN services sv ON sv.service_id=s.service_id
+JOIN billing_periods bp ON bp.billing_period=s.billing_period
+CROSS JOIN LATERAL (
 	SELECT
-		AGE(LEAST(COALESCE(stop_date, $2),$2),GREATEST(start_date,$1)) AS inter,
-		price
-	FROM subscriptions
-	WHERE 
-		(start_date<=$2 AND (stop_date IS null OR stop_date>=$1))AND
-		($3::uuid IS null or user_id=$3) AND
-		($4::character varying IS null or service_id=(SELECT service_id FROM services WHERE "name"=$4)) 
-	)
-SELECT 	COALESCE (SUM( (EXTRACT(YEAR FROM inter)*12 + EXTRACT (MONTH FROM inter)+1)*price),0) FROM t`
+		(m.period+interval '1 month')::date-1 AS month_end,
+		COALESCE(s.stop_date,'infinity'::date) AS stop_date,
+		((date_part('year',m.period)-date_part('year',s.start_date))*12+
+			date_part('month',m.period)-date_part('month',s.start_date))::integer AS months
+	) d
+CROSS JOIN LATERAL (
+	-- number of charge events of the subscription within the month: monthly based
+	-- periods charge on the start day every bp.months months, day based periods
+	-- every bp.days days since the start date
+	SELECT
+		CASE
+			WHEN bp.months>0 THEN
+				CASE WHEN d.months%bp.months=0 AND (s.start_date+make_interval(months=>d.months))::date<=d.stop_date THEN 1 ELSE 0 END
+			ELSE
+				(LEAST(d.month_end,d.stop_date)-s.start_date)/bp.days-
+				(GREATEST(m.period,s.start_date)-s.start_date+bp.days-1)/bp.days+1
+		END AS charges,
+		-- prorated totals spread the price evenly over the days of the billing cycle
+		LEAST(d.month_end,d.stop_date)-GREATEST(m.period,s.start_date)+1 AS active_days,
+		CASE
+			WHEN bp.months>0 THEN (m.period+make_interval(months=>bp.months))::date-m.period
+			ELSE bp.days
+		END AS cycle_days,
+		-- the price of the month is the one effective on the charge day of monthly
+		-- based periods or on the first active day of the month otherwise
+		CASE
+			WHEN bp.months>0 THEN (s.start_date+make_interval(months=>d.months))::date
+			ELSE GREATEST(m.period,s.start_date)
+		END AS price_date
+	) c
+CROSS JOIN LATERAL (
+	SELECT COALESCE((
+		SELECT sp.price
+		FROM subscription_prices sp
+		WHERE sp.subscription_id=s.subscription_id AND sp.effective_date<=c.price_date
+		ORDER BY sp.effective_date DESC
+		LIMIT 1
+	),s.price) AS price
+	) p
+CROSS JOIN LATERAL (
+	-- the latest rate known in the month, quoted in either direction
+	SELECT CASE
+		WHEN s.currency=$8 THEN 1::numeric
+		ELSE (
+			SELECT CASE WHEN er.base_currency=s.currency THEN er.rate ELSE 1/er.rate END
+			FROM exchange_rates er
+			WHERE
+				((er.base_currency=s.currency AND er.quote_currency=$8) OR
+				(er.base_currency=$8 AND er.quote_currency=s.currency)) AND
+				er.effective_date<=d.month_end
+			ORDER BY er.effective_date DESC
+			LIMIT 1
+		)
+	END AS rate
+	) r
+WHERE
+	s.tenant_id=$10 AND
+	($3::uuid IS null OR s.user_id=$3) AND
+	($4::character varying IS null OR sv.name=$4) AND
+	($9 OR s.deleted_at IS null)
+)
+SELECT
+	m.period,
+	CASE WHEN $5 THEN i.service_id END,
+	CASE WHEN $5 THEN i.name END,
+	CASE WHEN $6 THEN i.user_id END,
+	count(i.period),
+	COALESCE(round(CASE
+		WHEN $7 THEN SUM(i.prorated)
+		ELSE SUM(i.charged)
+	END),0)::bigint,
+	COALESCE(bool_or(i.period IS NOT null AND i.rate IS null),false)
+FROM months m
+LEFT JOIN items i ON i.period=m.period
+GROUP BY 1,2,3,4
+ORDER BY 1,3,4`
+	addSubscriptionPriceQuery = `
+INSERT INTO subscription_prices (subscription_id,effective_date,price,tenant_id) 
+SELECT subscription_id,COALESCE($2::date,GREATEST($4::date,date_trunc('month',now())::date)),$3,tenant_id 
+FROM subscriptions 
+WHERE subscription_id=$1 AND tenant_id=$5 AND deleted_at IS null AND ($2::date IS NOT null OR price<>$3) 
+ON CONFLICT (subscription_id,effective_date) DO UPDATE SET price=EXCLUDED.price`
+	// the price of the subscription is the one effective today, or on the start
+	// date of a subscription that hasn't started, prices scheduled later don't
+	// apply yet
 	updateSubscriptionQuery = `
 UPDATE subscriptions 
-SET service_id=(SELECT service_id FROM services WHERE "name"=$2),price=$3,user_id=$4,start_date=$5,stop_date=$6 
-WHERE subscription_id=$1`
-	removeSubscriptionQuery = `DELETE FROM subscriptions WHERE subscription_id=$1`
+SET service_id=(SELECT service_id FROM services WHERE "name"=$2 AND tenant_id=$9 AND deleted_at IS null FOR SHARE),user_id=$3,start_date=$4,stop_date=$5,
+	billing_period=COALESCE($6,billing_period),currency=COALESCE($7,currency),
+	price=COALESCE((
+		SELECT sp.price FROM subscription_prices sp 
+		WHERE sp.subscription_id=$1 AND sp.effective_date<=GREATEST(current_date,$4::date) 
+		ORDER BY sp.effective_date DESC LIMIT 1
+	),price) 
+WHERE subscription_id=$1 AND tenant_id=$9 AND deleted_at IS null AND ($8::bigint[] IS null OR version=ANY($8))`
+	subscriptionExistsQuery    = `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE subscription_id=$1 AND tenant_id=$2 AND deleted_at IS null)`
+	getSubscriptionPricesQuery = `
+SELECT sp.effective_date,sp.price 
+FROM subscription_prices sp 
+JOIN subscriptions s ON s.subscription_id=sp.subscription_id AND s.deleted_at IS null 
+WHERE sp.subscription_id=$1 AND s.tenant_id=$2 
+ORDER BY sp.effective_date`
+	removeSubscriptionQuery = `
+UPDATE subscriptions SET deleted_at=now() 
+WHERE subscription_id=$1 AND tenant_id=$3 AND deleted_at IS null AND ($2::bigint[] IS null OR version=ANY($2))`
+	// the subscription is restored only together with its service, which is
+	// locked as by the other writes of a subscription so that it isn't removed
+	// at the same time; a subscription that isn't deleted isn't found
+	restoreSubscriptionQuery = `
+WITH sv AS (
+	SELECT sv.service_id,sv.deleted_at FROM subscriptions s JOIN services sv ON sv.service_id=s.service_id 
+	WHERE s.subscription_id=$1 AND s.tenant_id=$2 AND s.deleted_at IS NOT null 
+	FOR SHARE OF sv
+)
+UPDATE subscriptions s SET deleted_at=null 
+FROM sv 
+WHERE s.subscription_id=$1 AND s.tenant_id=$2 AND s.deleted_at IS NOT null AND sv.service_id=s.service_id 
+RETURNING sv.deleted_at IS null`
+
+	// the purge spans the tenants visible to the connection, every one for
+	// the background job
+	purgeSubscriptionsQuery = `DELETE FROM subscriptions WHERE deleted_at<$1`
+	purgeServicesQuery      = `
+DELETE FROM services sv 
+WHERE sv.deleted_at<$1 AND NOT EXISTS (SELECT 1 FROM subscriptions s WHERE s.service_id=sv.service_id)`
+
+	importExchangeRateQuery = `
+INSERT INTO exchange_rates (base_currency,quote_currency,effective_date,rate) 
+VALUES ($1,$2,$3,$4) 
+ON CONFLICT (base_currency,quote_currency,effective_date) DO UPDATE SET rate=EXCLUDED.rate`
+
+	// the data of a tenant changes with the rows of the tenant and with the
+	// exchange rates shared by every tenant, both revisions only grow
+	getDataRevisionQuery = `
+SELECT d.revision+coalesce(t.revision,0),greatest(d.modified_at,t.modified_at)
+FROM data_revision d LEFT JOIN tenant_data_revision t ON t.tenant_id=$1
+WHERE d.revision_id=1`
+
+	setActorQuery      = `SELECT set_config('app.actor',$1,true)`
+	setTenantQuery     = `SELECT set_config('app.tenant_id',$1,false)`
+	bypassRLSQuery     = `SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname=current_user`
+	getAuditLogQuery   = `SELECT audit_id,changed_at,actor,operation,entity,entity_id,before,after FROM audit_log`
+	countAuditLogQuery = `SELECT count(*) FROM audit_log`
+)
+
+const (
+	notNullViolation    = "23502"
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+	checkViolation      = "23514"
 )
 
 type Repository interface {
-	AddService(name string) (*model.Service, error)
-	GetService(serviceId int) (*model.Service, error)
-	GetServices() ([]*model.Service, error)
-	UpdateService(dto *dto.UpdateService) error
-	RemoveService(serviceId int) error
-	AddSubscription(dto *dto.AddSubscription) (*model.Subscription, error)
-	GetSubscription(subscriptionId int) (*model.Subscription, error)
-	GetSubscriptions(dto *dto.GetSubscriptions) ([]*model.Subscription, error)
-	GetSubscriptionTotal(ctx *dto.GetSubscriptionTotal) (int, error)
-	UpdateSubscription(dto *dto.UpdateSubscription) error
-	RemoveSubscription(subscriptionId int) error
+	Ping(ctx context.Context) error
+	GetPoolStats() *model.PoolStats
+
+	AddService(ctx context.Context, name string) (*model.Service, error)
+	GetService(ctx context.Context, dto *dto.GetService) (*model.Service, error)
+	GetServices(ctx context.Context, dto *dto.GetServices) ([]*model.Service, int, error)
+	UpdateService(ctx context.Context, dto *dto.UpdateService) error
+	RemoveService(ctx context.Context, dto *dto.RemoveService) error
+	RestoreService(ctx context.Context, serviceId int) error
+	AddSubscription(ctx context.Context, dto *dto.AddSubscription) (*model.Subscription, error)
+	GetSubscription(ctx context.Context, dto *dto.GetSubscription) (*model.Subscription, error)
+	GetSubscriptions(ctx context.Context, dto *dto.GetSubscriptions) ([]*model.Subscription, int, error)
+	ExportSubscriptions(ctx context.Context, dto *dto.ExportSubscriptions) (model.SubscriptionCursor, error)
+	GetSubscriptionTotal(ctx context.Context, dto *dto.GetSubscriptionTotal) ([]*model.SubscriptionTotalRow, error)
+	UpdateSubscription(ctx context.Context, dto *dto.UpdateSubscription) error
+	GetSubscriptionPrices(ctx context.Context, subscriptionId int) ([]*model.SubscriptionPrice, error)
+	RemoveSubscription(ctx context.Context, dto *dto.RemoveSubscription) error
+	RestoreSubscription(ctx context.Context, subscriptionId int) error
+	BatchSubscriptions(ctx context.Context, ops []*dto.SubscriptionOperation, atomic bool) ([]*model.BatchResult, error)
+	ImportSubscriptions(ctx context.Context, dto *dto.ImportSubscriptions) ([]*model.BatchResult, []string, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int, int, error)
+
+	ImportExchangeRates(ctx context.Context, rates []*model.ExchangeRate) error
+
+	GetDataRevision(ctx context.Context) (*model.DataRevision, error)
+
+	GetAuditLog(ctx context.Context, dto *dto.GetAuditLog) ([]*model.AuditEntry, int, error)
+
+	AddAPIKey(ctx context.Context, dto *dto.AddAPIKey) (*model.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]*model.APIKey, error)
+	RotateAPIKey(ctx context.Context, dto *dto.RotateAPIKey) (*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, apiKeyId int64) error
+	AuthenticateAPIKey(ctx context.Context, keyHash string) (*model.APIKey, error)
+
+	ClaimIdempotencyKey(ctx context.Context, key string, fingerprint string, ttl time.Duration, lease time.Duration) (*model.IdempotentResponse, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, resp *model.IdempotentResponse) error
+	RemoveIdempotencyKey(ctx context.Context, key string) error
+	RemoveExpiredIdempotencyKeys(ctx context.Context) (int, error)
 }
 
 type repository struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
 	lg   *zap.SugaredLogger
 }
 
-func MustNew(lg *zap.SugaredLogger, cfg *config.Db) *repository {
-	connString := fmt.Sprintf(
+func connString(cfg *config.Db, user, password string) string {
+	return fmt.Sprintf(
 		"user=%s password=%s host=%s port=%d dbname=%s sslmode=%s",
-		cfg.User,
-		cfg.Password,
+		user,
+		password,
 		cfg.Host,
 		cfg.Port,
 		cfg.Name,
 		cfg.SSLMode,
 	)
-	var (
-		conn  *pgx.Conn
-		err   error
-		count int
-	)
+}
+
+func MustNew(lg *zap.SugaredLogger, cfg *config.Db) *repository {
+	poolConfig, err := pgxpool.ParseConfig(connString(cfg, cfg.User, cfg.Password))
+	if err != nil {
+		lg.Fatalf("failed to parse repository config: %v", err)
+	}
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	// a connection is limited to the tenant of the context it is acquired
+	// with by the row level security policies
+	poolConfig.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		if _, err := conn.Exec(ctx, setTenantQuery, tenant.FromContext(ctx)); err != nil {
+			if ctx.Err() == nil {
+				lg.Errorf("failed to set tenant of connection: %v", err)
+			}
+			return false
+		}
+		return true
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		lg.Fatalf("failed to create repository pool: %v", err)
+	}
+
+	var count int
 	for {
 		count++
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-		conn, err = pgx.Connect(ctx, connString)
+		err = pool.Ping(ctx)
+		cancel()
 		if err != nil {
 			lg.Errorf("failed to connect repository(%d): %v", count, err)
 			if count > 4 {
-				lg.Fatalf("failed to connect repository", err)
+				lg.Fatalf("failed to connect repository: %v", err)
 			}
 			time.Sleep(1 * time.Second)
 			continue
@@ -105,217 +337,612 @@ func MustNew(lg *zap.SugaredLogger, cfg *config.Db) *repository {
 	lg.Info("repository connect successfully")
 
 	// migration
-	config := conn.Config()
-	db := stdlib.OpenDB(*config)
+	// the tables are owned by the migration user, the service itself connects
+	// as a user the row level security policies apply to
+	var db *sql.DB
+	if cfg.MigrationUser != "" {
+		connConfig, err := pgx.ParseConfig(connString(cfg, cfg.MigrationUser, cfg.MigrationPassword))
+		if err != nil {
+			lg.Fatalf("failed to parse migration config: %v", err)
+		}
+		db = stdlib.OpenDB(*connConfig)
+	} else {
+		db = stdlib.OpenDBFromPool(pool)
+	}
 	if err := migration.Up(db, cfg.MigrationPath); err != nil {
 		lg.Errorf("migration failed: %v", err)
 	} else {
 		lg.Info("migration completed successfully")
 	}
+	if err := db.Close(); err != nil {
+		lg.Errorf("failed to close migration connection: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.QueryTimeout)
+	defer cancel()
+	var bypassRLS bool
+	if err := pool.QueryRow(ctx, bypassRLSQuery).Scan(&bypassRLS); err != nil {
+		lg.Fatalf("failed to check database user: %v", err)
+	}
+	if bypassRLS {
+		lg.Fatalf("database user %s bypasses row level security, connect as a member of subscription_app", cfg.User)
+	}
 
 	return &repository{
-		conn: conn,
+		pool: pool,
 		lg:   lg,
 	}
 }
 func (r *repository) Close() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := r.conn.Close(ctx); err != nil {
-		r.lg.Errorf("failed to disconnect repository: %v", err)
-		return
-	}
+	r.pool.Close()
 	r.lg.Info("repository disconnect successfully")
 }
 
-func (r *repository) AddService(name string) (*model.Service, error) {
+func (r *repository) Ping(ctx context.Context) error {
+	if err := r.pool.Ping(ctx); err != nil {
+		return r.queryError(err, "failed to ping repository")
+	}
+	return nil
+}
+func (r *repository) GetPoolStats() *model.PoolStats {
+	stat := r.pool.Stat()
+	return &model.PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDuration:         stat.AcquireDuration().Milliseconds(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		EmptyAcquireWaitTime:    stat.EmptyAcquireWaitTime().Milliseconds(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+// inTx runs fn in a transaction attributed to the actor of ctx; the audit
+// triggers read the actor from the app.actor setting.
+func (r *repository) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, setActorQuery, actor.FromContext(ctx)); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// notChanged tells why a change of a record matched no row: the record is
+// either missing or has a version other than the expected one.
+func notChanged(ctx context.Context, tx pgx.Tx, existsQuery string, id int) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, existsQuery, id, tenant.FromContext(ctx)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return servererrors.ErrorVersionMismatch
+	}
+	return servererrors.ErrorRecordNotFound
+}
+
+func (r *repository) queryError(err error, msg string) error {
+	if errors.Is(err, servererrors.ErrorRecordNotFound) || errors.Is(err, servererrors.ErrorVersionMismatch) {
+		return err
+	}
+	if err := constraintError(err); err != nil {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return servererrors.ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return servererrors.ErrorTimeout
+	}
+	r.lg.Errorf("%s: %v", msg, err)
+	return servererrors.ErrorInternal
+}
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case notNullViolation:
+		if pgErr.TableName == "subscriptions" && pgErr.ColumnName == "service_id" {
+			return servererrors.ErrorServiceNotFound
+		}
+	case foreignKeyViolation:
+		if pgErr.ConstraintName == "services_fk" {
+			return servererrors.ErrorServiceNotFound
+		}
+	case uniqueViolation:
+		if pgErr.ConstraintName == "services_name" {
+			return servererrors.ErrorServiceExists
+		}
+	case checkViolation:
+		if pgErr.ConstraintName == "start_date_stop_date" {
+			return servererrors.ErrorInvalidPeriod
+		}
+	}
+	return nil
+}
+
+func (r *repository) AddService(ctx context.Context, name string) (*model.Service, error) {
 	service := new(model.Service)
-	err := r.conn.QueryRow(context.Background(), addServiceQuery, name).Scan(&service.ServiceId, &service.Name)
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, addServiceQuery, name, tenant.FromContext(ctx)).Scan(&service.ServiceId, &service.Name, &service.DeletedAt, &service.Version)
+	})
 	if err != nil {
-		r.lg.Errorf("failed to add service: %v", err)
-		return nil, servererrors.ErrorInternal
+		return nil, r.queryError(err, "failed to add service")
 	}
 	return service, nil
 }
-func (r *repository) GetService(serviceId int) (*model.Service, error) {
+func (r *repository) GetService(ctx context.Context, dto *dto.GetService) (*model.Service, error) {
 	service := new(model.Service)
-	err := r.conn.QueryRow(context.Background(), getServiceQuery, serviceId).Scan(&service.ServiceId, &service.Name)
+	err := r.pool.QueryRow(ctx, getServiceQuery, dto.ServiceId, dto.IncludeDeleted, tenant.FromContext(ctx)).Scan(&service.ServiceId, &service.Name, &service.DeletedAt, &service.Version)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, servererrors.ErrorRecordNotFound
 	}
 	if err != nil {
-		r.lg.Errorf("failed to get service: %v", err)
-		return nil, servererrors.ErrorInternal
+		return nil, r.queryError(err, "failed to get service")
 	}
 	return service, err
 }
-func (r *repository) GetServices() ([]*model.Service, error) {
-	rows, err := r.conn.Query(context.Background(), getServicesQuery)
+func (r *repository) GetServices(ctx context.Context, dto *dto.GetServices) ([]*model.Service, int, error) {
+	if err := checkSort(serviceSortColumns, dto.Sort, "service_id"); err != nil {
+		return nil, 0, r.queryError(err, "failed to get services")
+	}
+	b := new(queryBuilder)
+	b.where("tenant_id=%s", tenant.FromContext(ctx))
+	if !dto.IncludeDeleted {
+		b.where("deleted_at IS null")
+	}
+
+	var total int
+	if err := r.pool.QueryRow(ctx, countServicesQuery+b.whereClause(), b.args...).Scan(&total); err != nil {
+		return nil, 0, r.queryError(err, "failed to count services")
+	}
+
+	backward := dto.Keyset != nil && dto.Keyset.Backward
+	if dto.Keyset != nil {
+		if err := b.keyset(serviceSortColumns, dto.Sort, dto.Keyset); err != nil {
+			return nil, 0, err
+		}
+	}
+	query := getServicesQuery + b.whereClause() + orderBy(serviceSortColumns, dto.Sort, backward) +
+		fmt.Sprintf(" OFFSET %s LIMIT %s", b.arg(dto.Offset), b.arg(dto.Limit))
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
-		r.lg.Errorf("failed to get services: %v", err)
-		return nil, err
+		return nil, 0, r.queryError(err, "failed to get services")
 	}
 	defer rows.Close()
 
 	services := []*model.Service{}
 	for rows.Next() {
 		service := new(model.Service)
-		err := rows.Scan(&service.ServiceId, &service.Name)
+		err := rows.Scan(&service.ServiceId, &service.Name, &service.DeletedAt, &service.Version)
 		if err != nil {
-			r.lg.Errorf("failed to get services: %v", err)
-			return nil, servererrors.ErrorInternal
+			return nil, 0, r.queryError(err, "failed to get services")
 		}
 		services = append(services, service)
 	}
-	return services, nil
+	if err := rows.Err(); err != nil {
+		return nil, 0, r.queryError(err, "failed to get services")
+	}
+	if backward {
+		slices.Reverse(services)
+	}
+	return services, total, nil
 }
-func (r *repository) UpdateService(dto *dto.UpdateService) error {
-	result, err := r.conn.Exec(context.Background(), updateServiceQuery, dto.ServiceId, dto.Name)
+func (r *repository) UpdateService(ctx context.Context, dto *dto.UpdateService) error {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, updateServiceQuery, dto.ServiceId, dto.Name, dto.Versions, tenant.FromContext(ctx))
+		if err == nil && result.RowsAffected() == 0 {
+			return notChanged(ctx, tx, serviceExistsQuery, dto.ServiceId)
+		}
+		return err
+	})
 	if err != nil {
-		r.lg.Errorf("failed to update service: %v", err)
-		return servererrors.ErrorInternal
-	}
-	if result.RowsAffected() == 0 {
-		return servererrors.ErrorRecordNotFound
+		return r.queryError(err, "failed to update service")
 	}
 	return nil
 }
-func (r *repository) RemoveService(serviceId int) error {
-	result, err := r.conn.Exec(context.Background(), removeServiceQuery, serviceId)
+func (r *repository) RemoveService(ctx context.Context, dto *dto.RemoveService) error {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		var version int64
+		err := tx.QueryRow(ctx, lockServiceQuery, dto.ServiceId, tenant.FromContext(ctx)).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return servererrors.ErrorRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if dto.Versions != nil && !slices.Contains(dto.Versions, version) {
+			return servererrors.ErrorVersionMismatch
+		}
+		var inUse bool
+		if err := tx.QueryRow(ctx, serviceInUseQuery, dto.ServiceId, tenant.FromContext(ctx)).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return servererrors.ErrorServiceInUse
+		}
+		_, err = tx.Exec(ctx, removeServiceQuery, dto.ServiceId, tenant.FromContext(ctx))
+		return err
+	})
+	if errors.Is(err, servererrors.ErrorServiceInUse) {
+		return err
+	}
 	if err != nil {
-		r.lg.Errorf("failed to remove service: %v", err)
-		return servererrors.ErrorInternal
+		return r.queryError(err, "failed to remove service")
 	}
-	if result.RowsAffected() == 0 {
-		return servererrors.ErrorRecordNotFound
+	return nil
+}
+func (r *repository) RestoreService(ctx context.Context, serviceId int) error {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, restoreServiceQuery, serviceId, tenant.FromContext(ctx))
+		if err == nil && result.RowsAffected() == 0 {
+			return servererrors.ErrorRecordNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return r.queryError(err, "failed to restore service")
 	}
 	return nil
 }
 
-func (r *repository) AddSubscription(dto *dto.AddSubscription) (*model.Subscription, error) {
-
-	subscription := new(model.Subscription)
-	err := r.conn.QueryRow(
-		context.Background(),
-		addSubscriptionQuery,
-		dto.ServiceName,
-		dto.Price,
-		dto.UserId,
-		dto.StartDate,
-		dto.StopDate,
-	).Scan(
+// subscriptionFields returns the scan destinations of subscriptionColumns.
+func subscriptionFields(subscription *model.Subscription) []any {
+	return []any{
 		&subscription.SubscriptionId,
 		&subscription.ServiceId,
 		&subscription.Price,
+		&subscription.Currency,
 		&subscription.UserId,
 		&subscription.StartDate,
 		&subscription.StopDate,
-	)
+		&subscription.BillingPeriod,
+		&subscription.DeletedAt,
+		&subscription.Version,
+	}
+}
+func addSubscriptionArgs(tenantId string, dto *dto.AddSubscription) []any {
+	return []any{
+		dto.ServiceName,
+		dto.Price,
+		dto.Currency,
+		dto.UserId,
+		dto.StartDate,
+		dto.StopDate,
+		dto.BillingPeriod,
+		tenantId,
+	}
+}
+func addSubscriptionPriceArgs(tenantId string, dto *dto.UpdateSubscription) []any {
+	return []any{
+		dto.SubscriptionId,
+		dto.PriceEffectiveDate,
+		dto.Price,
+		dto.StartDate,
+		tenantId,
+	}
+}
+func updateSubscriptionArgs(tenantId string, dto *dto.UpdateSubscription) []any {
+	return []any{
+		dto.SubscriptionId,
+		dto.ServiceName,
+		dto.UserId,
+		dto.StartDate,
+		dto.StopDate,
+		dto.BillingPeriod,
+		dto.Currency,
+		dto.Versions,
+		tenantId,
+	}
+}
+
+func (r *repository) AddSubscription(ctx context.Context, dto *dto.AddSubscription) (*model.Subscription, error) {
+
+	subscription := new(model.Subscription)
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, addSubscriptionQuery, addSubscriptionArgs(tenant.FromContext(ctx), dto)...).Scan(subscriptionFields(subscription)...)
+	})
 	if err != nil {
-		r.lg.Errorf("failed to add subscription: %v", err)
-		return nil, servererrors.ErrorInternal
+		return nil, r.queryError(err, "failed to add subscription")
 	}
 
 	return subscription, nil
 }
-func (r *repository) GetSubscription(subscriptionId int) (*model.Subscription, error) {
+func (r *repository) GetSubscription(ctx context.Context, dto *dto.GetSubscription) (*model.Subscription, error) {
 
 	subscription := new(model.Subscription)
-	err := r.conn.QueryRow(
-		context.Background(),
+	err := r.pool.QueryRow(
+		ctx,
 		getSubscriptionQuery,
-		subscriptionId,
-	).Scan(
-		&subscription.SubscriptionId,
-		&subscription.ServiceId,
-		&subscription.Price,
-		&subscription.UserId,
-		&subscription.StartDate,
-		&subscription.StopDate,
-	)
+		dto.SubscriptionId,
+		dto.IncludeDeleted,
+		tenant.FromContext(ctx),
+	).Scan(subscriptionFields(subscription)...)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, servererrors.ErrorRecordNotFound
 	}
 	if err != nil {
-		r.lg.Errorf("failed to get subscription: %v", err)
-		return nil, servererrors.ErrorInternal
+		return nil, r.queryError(err, "failed to get subscription")
 	}
 	return subscription, nil
 }
 
-func (r *repository) GetSubscriptions(dto *dto.GetSubscriptions) ([]*model.Subscription, error) {
-	rows, err := r.conn.Query(context.Background(), getSubscriptionsQuery, dto.Offset, dto.Limit)
+func (r *repository) GetSubscriptions(ctx context.Context, dto *dto.GetSubscriptions) ([]*model.Subscription, int, error) {
+	if err := checkSort(subscriptionSortColumns, dto.Sort, "subscription_id"); err != nil {
+		return nil, 0, r.queryError(err, "failed to get subscriptions")
+	}
+	b := subscriptionFilter(tenant.FromContext(ctx), &dto.Filter)
+
+	var total int
+	if err := r.pool.QueryRow(ctx, countSubscriptionsQuery+b.whereClause(), b.args...).Scan(&total); err != nil {
+		return nil, 0, r.queryError(err, "failed to count subscriptions")
+	}
+
+	backward := dto.Keyset != nil && dto.Keyset.Backward
+	if dto.Keyset != nil {
+		if err := b.keyset(subscriptionSortColumns, dto.Sort, dto.Keyset); err != nil {
+			return nil, 0, err
+		}
+	}
+	query := getSubscriptionsQuery + b.whereClause() + orderBy(subscriptionSortColumns, dto.Sort, backward) +
+		fmt.Sprintf(" OFFSET %s LIMIT %s", b.arg(dto.Offset), b.arg(dto.Limit))
+	rows, err := r.pool.Query(ctx, query, b.args...)
 	if err != nil {
-		r.lg.Errorf("failed to get subscriptions: %v", err)
-		return nil, err
+		return nil, 0, r.queryError(err, "failed to get subscriptions")
 	}
 	defer rows.Close()
 
 	subscriptions := []*model.Subscription{}
 	for rows.Next() {
 		subscription := new(model.Subscription)
-		err := rows.Scan(
-			&subscription.SubscriptionId,
-			&subscription.ServiceId,
-			&subscription.Price,
-			&subscription.UserId,
-			&subscription.StartDate,
-			&subscription.StopDate,
-		)
+		err := rows.Scan(subscriptionFields(subscription)...)
 		if err != nil {
-			r.lg.Errorf("failed to get subscriptions: %v", err)
-			return nil, servererrors.ErrorInternal
+			return nil, 0, r.queryError(err, "failed to get subscriptions")
 		}
 		subscriptions = append(subscriptions, subscription)
 	}
-	return subscriptions, nil
+	if err := rows.Err(); err != nil {
+		return nil, 0, r.queryError(err, "failed to get subscriptions")
+	}
+	if backward {
+		slices.Reverse(subscriptions)
+	}
+	return subscriptions, total, nil
 }
-func (r *repository) GetSubscriptionTotal(dto *dto.GetSubscriptionTotal) (int, error) {
-	var total int
-	err := r.conn.QueryRow(
-		context.Background(),
+func (r *repository) GetSubscriptionTotal(ctx context.Context, dto *dto.GetSubscriptionTotal) ([]*model.SubscriptionTotalRow, error) {
+	rows, err := r.pool.Query(
+		ctx,
 		getSubscriptionTotalQuery,
 		dto.StartDate,
 		dto.StopDate,
 		dto.UserId,
 		dto.ServiceName,
-	).Scan(
-		&total,
+		dto.GroupByService,
+		dto.GroupByUser,
+		dto.Prorate,
+		dto.Currency,
+		dto.IncludeDeleted,
+		tenant.FromContext(ctx),
 	)
 	if err != nil {
-		r.lg.Errorf("failed to get subscription total: %v", err)
-		return 0, servererrors.ErrorInternal
+		return nil, r.queryError(err, "failed to get subscription total")
+	}
+	defer rows.Close()
+
+	totalRows := []*model.SubscriptionTotalRow{}
+	missingRate := false
+	for rows.Next() {
+		totalRow := new(model.SubscriptionTotalRow)
+		var missing bool
+		err := rows.Scan(
+			&totalRow.Period,
+			&totalRow.ServiceId,
+			&totalRow.ServiceName,
+			&totalRow.UserId,
+			&totalRow.Count,
+			&totalRow.Amount,
+			&missing,
+		)
+		if err != nil {
+			return nil, r.queryError(err, "failed to get subscription total")
+		}
+		missingRate = missingRate || missing
+		totalRows = append(totalRows, totalRow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, r.queryError(err, "failed to get subscription total")
 	}
-	return total, nil
+	if missingRate {
+		return nil, servererrors.ErrorExchangeRateNotFound
+	}
+	return totalRows, nil
 }
-func (r *repository) UpdateSubscription(dto *dto.UpdateSubscription) error {
-	result, err := r.conn.Exec(
-		context.Background(),
-		updateSubscriptionQuery,
-		dto.SubscriptionId,
-		dto.ServiceName,
-		dto.Price,
-		dto.UserId,
-		dto.StartDate,
-		dto.StopDate,
-	)
+func (r *repository) UpdateSubscription(ctx context.Context, dto *dto.UpdateSubscription) error {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		// a changed price becomes a new version instead of rewriting the history,
+		// the subscription keeps the latest one
+		if _, err := tx.Exec(ctx, addSubscriptionPriceQuery, addSubscriptionPriceArgs(tenant.FromContext(ctx), dto)...); err != nil {
+			return err
+		}
+		result, err := tx.Exec(ctx, updateSubscriptionQuery, updateSubscriptionArgs(tenant.FromContext(ctx), dto)...)
+		if err == nil && result.RowsAffected() == 0 {
+			return notChanged(ctx, tx, subscriptionExistsQuery, dto.SubscriptionId)
+		}
+		return err
+	})
 	if err != nil {
-		r.lg.Errorf("failed to update subscription: %v", err)
-		return servererrors.ErrorInternal
+		return r.queryError(err, "failed to update subscription")
 	}
-	if result.RowsAffected() == 0 {
-		return servererrors.ErrorRecordNotFound
+	return nil
+}
+func (r *repository) GetSubscriptionPrices(ctx context.Context, subscriptionId int) ([]*model.SubscriptionPrice, error) {
+	rows, err := r.pool.Query(ctx, getSubscriptionPricesQuery, subscriptionId, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, r.queryError(err, "failed to get subscription prices")
+	}
+	defer rows.Close()
+
+	prices := []*model.SubscriptionPrice{}
+	for rows.Next() {
+		price := new(model.SubscriptionPrice)
+		if err := rows.Scan(&price.EffectiveDate, &price.Price); err != nil {
+			return nil, r.queryError(err, "failed to get subscription prices")
+		}
+		prices = append(prices, price)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, r.queryError(err, "failed to get subscription prices")
+	}
+	if len(prices) == 0 {
+		return nil, servererrors.ErrorRecordNotFound
+	}
+	return prices, nil
+}
+func (r *repository) RemoveSubscription(ctx context.Context, dto *dto.RemoveSubscription) error {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, removeSubscriptionQuery, dto.SubscriptionId, dto.Versions, tenant.FromContext(ctx))
+		if err == nil && result.RowsAffected() == 0 {
+			return notChanged(ctx, tx, subscriptionExistsQuery, dto.SubscriptionId)
+		}
+		return err
+	})
+	if err != nil {
+		return r.queryError(err, "failed to remove subscription")
+	}
+	return nil
+}
+func (r *repository) RestoreSubscription(ctx context.Context, subscriptionId int) error {
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		var serviceActive bool
+		err := tx.QueryRow(ctx, restoreSubscriptionQuery, subscriptionId, tenant.FromContext(ctx)).Scan(&serviceActive)
+		if errors.Is(err, sql.ErrNoRows) {
+			return servererrors.ErrorRecordNotFound
+		}
+		if err == nil && !serviceActive {
+			return servererrors.ErrorServiceNotFound
+		}
+		return err
+	})
+	if errors.Is(err, servererrors.ErrorServiceNotFound) {
+		return err
+	}
+	if err != nil {
+		return r.queryError(err, "failed to restore subscription")
 	}
 	return nil
 }
-func (r *repository) RemoveSubscription(subscriptionId int) error {
-	result, err := r.conn.Exec(context.Background(), removeSubscriptionQuery, subscriptionId)
+
+// PurgeDeleted removes the subscriptions and the services deleted before the
+// given time for good and returns the number of each removed.
+func (r *repository) PurgeDeleted(ctx context.Context, before time.Time) (int, int, error) {
+	var subscriptions, services int64
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, purgeSubscriptionsQuery, before)
+		if err != nil {
+			return err
+		}
+		subscriptions = result.RowsAffected()
+		result, err = tx.Exec(ctx, purgeServicesQuery, before)
+		if err != nil {
+			return err
+		}
+		services = result.RowsAffected()
+		return nil
+	})
 	if err != nil {
-		r.lg.Errorf("failed to remove subscription: %v", err)
-		return servererrors.ErrorInternal
+		return 0, 0, r.queryError(err, "failed to purge deleted records")
+	}
+	return int(subscriptions), int(services), nil
+}
+
+func (r *repository) ImportExchangeRates(ctx context.Context, rates []*model.ExchangeRate) error {
+	batch := new(pgx.Batch)
+	for _, rate := range rates {
+		batch.Queue(importExchangeRateQuery, rate.BaseCurrency, rate.QuoteCurrency, rate.EffectiveDate, rate.Rate)
 	}
-	if result.RowsAffected() == 0 {
-		return servererrors.ErrorRecordNotFound
+	err := r.inTx(ctx, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return r.queryError(err, "failed to import exchange rates")
 	}
 	return nil
 }
+
+func (r *repository) GetDataRevision(ctx context.Context) (*model.DataRevision, error) {
+	revision := new(model.DataRevision)
+	err := r.pool.QueryRow(ctx, getDataRevisionQuery, tenant.FromContext(ctx)).Scan(&revision.Revision, &revision.ModifiedAt)
+	if err != nil {
+		return nil, r.queryError(err, "failed to get data revision")
+	}
+	return revision, nil
+}
+
+func (r *repository) GetAuditLog(ctx context.Context, dto *dto.GetAuditLog) ([]*model.AuditEntry, int, error) {
+	if err := checkSort(auditSortColumns, dto.Sort, "audit_id"); err != nil {
+		return nil, 0, r.queryError(err, "failed to get audit log")
+	}
+	b := auditFilter(tenant.FromContext(ctx), &dto.Filter)
+
+	var total int
+	if err := r.pool.QueryRow(ctx, countAuditLogQuery+b.whereClause(), b.args...).Scan(&total); err != nil {
+		return nil, 0, r.queryError(err, "failed to count audit log")
+	}
+
+	backward := dto.Keyset != nil && dto.Keyset.Backward
+	if dto.Keyset != nil {
+		if err := b.keyset(auditSortColumns, dto.Sort, dto.Keyset); err != nil {
+			return nil, 0, err
+		}
+	}
+	query := getAuditLogQuery + b.whereClause() + orderBy(auditSortColumns, dto.Sort, backward) +
+		fmt.Sprintf(" OFFSET %s LIMIT %s", b.arg(dto.Offset), b.arg(dto.Limit))
+	rows, err := r.pool.Query(ctx, query, b.args...)
+	if err != nil {
+		return nil, 0, r.queryError(err, "failed to get audit log")
+	}
+	defer rows.Close()
+
+	entries := []*model.AuditEntry{}
+	for rows.Next() {
+		entry := new(model.AuditEntry)
+		err := rows.Scan(
+			&entry.AuditId,
+			&entry.ChangedAt,
+			&entry.Actor,
+			&entry.Operation,
+			&entry.Entity,
+			&entry.EntityId,
+			&entry.Before,
+			&entry.After,
+		)
+		if err != nil {
+			return nil, 0, r.queryError(err, "failed to get audit log")
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, r.queryError(err, "failed to get audit log")
+	}
+	if backward {
+		slices.Reverse(entries)
+	}
+	return entries, total, nil
+}
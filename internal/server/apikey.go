@@ -0,0 +1,49 @@
+package server
+
+import (
+	svcDto "subscription/internal/service/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) AddAPIKey(ctx *fiber.Ctx) error {
+	req := new(svcDto.AddAPIKey)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	resp, err := h.svc.AddAPIKey(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Status(201).JSON(resp)
+}
+func (h *handler) GetAPIKeys(ctx *fiber.Ctx) error {
+	resp, err := h.svc.GetAPIKeys(ctx.UserContext())
+	if err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(fiber.Map{"items": resp})
+}
+func (h *handler) RotateAPIKey(ctx *fiber.Ctx) error {
+	req := new(svcDto.RotateAPIKey)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	resp, err := h.svc.RotateAPIKey(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) RevokeAPIKey(ctx *fiber.Ctx) error {
+	req := new(svcDto.RevokeAPIKey)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	if err := h.svc.RevokeAPIKey(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"subscription/internal/config"
+	"subscription/internal/pkg/actor"
+	"subscription/internal/pkg/auth"
+	"subscription/internal/pkg/jwt"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	"subscription/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// apiKeyHeader carries the API key of a machine client.
+const apiKeyHeader = "X-API-Key"
+
+func newVerifier(cfg *config.Srv) (*jwt.Verifier, error) {
+	jwtCfg := &jwt.Config{
+		Secret:   []byte(cfg.JWTSecret),
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   cfg.JWTLeeway,
+	}
+	if cfg.JWKSPath != "" {
+		jwks, err := os.ReadFile(cfg.JWKSPath)
+		if err != nil {
+			return nil, err
+		}
+		jwtCfg.JWKS = jwks
+	}
+	return jwt.NewVerifier(jwtCfg)
+}
+
+// authenticate accepts requests with a valid bearer token or API key and
+// makes its subject the actor and its tenant the tenant of the request.
+func authenticate(svc service.Service, verifier *jwt.Verifier, lg *zap.SugaredLogger) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if key := ctx.Get(apiKeyHeader); key != "" {
+			principal, err := svc.AuthenticateAPIKey(ctx.UserContext(), key)
+			if err != nil {
+				lg.Debugf("request %s %s rejected: %v", ctx.Method(), ctx.Path(), err)
+				return err
+			}
+			return authenticated(ctx, principal)
+		}
+		scheme, token, ok := strings.Cut(ctx.Get(fiber.HeaderAuthorization), " ")
+		token = strings.TrimSpace(token)
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			ctx.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return servererrors.ErrorUnauthenticated
+		}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			lg.Debugf("request %s %s rejected: %v", ctx.Method(), ctx.Path(), err)
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return fmt.Errorf("%w: %w", servererrors.ErrorInvalidToken, err)
+		}
+		if !tenant.Valid(claims.Tenant) {
+			lg.Debugf("request %s %s rejected: token without a valid tenant_id", ctx.Method(), ctx.Path())
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return fmt.Errorf("%w: missing or invalid tenant_id", servererrors.ErrorInvalidToken)
+		}
+		return authenticated(ctx, &auth.Principal{
+			Subject:   claims.Subject,
+			Tenant:    claims.Tenant,
+			Roles:     claims.Roles,
+			ExpiresAt: claims.ExpiresAt,
+		})
+	}
+}
+
+func authenticated(ctx *fiber.Ctx, principal *auth.Principal) error {
+	userCtx := auth.NewContext(ctx.UserContext(), principal)
+	userCtx = tenant.NewContext(userCtx, principal.Tenant)
+	ctx.SetUserContext(actor.NewContext(userCtx, principal.Subject))
+	return ctx.Next()
+}
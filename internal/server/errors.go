@@ -0,0 +1,250 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"subscription/internal/pkg/servererrors"
+
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.uber.org/zap"
+)
+
+const problemContentType = "application/problem+json"
+
+const (
+	codeMalformedRequest = "malformed_request"
+	codeValidationFailed = "validation_failed"
+	codeNotFound         = "not_found"
+	codeServiceNotFound  = "service_not_found"
+	codeServiceExists    = "service_already_exists"
+	codeServiceInUse     = "service_in_use"
+	codeInvalidPeriod    = "invalid_period"
+	codePeriodTooLong    = "period_too_long"
+	codeInvalidCursor    = "invalid_cursor"
+	codeVersionMismatch  = "version_mismatch"
+	codeUnauthenticated  = "unauthenticated"
+	codeInvalidToken     = "invalid_token"
+	codeInvalidAPIKey    = "invalid_api_key"
+	codeForbidden        = "forbidden"
+	codeInvalidTenant    = "invalid_tenant"
+	codeKeyReused        = "idempotency_key_reused"
+	codeKeyInProgress    = "idempotency_key_in_progress"
+	codeRateNotFound     = "exchange_rate_not_found"
+	codeRequestCanceled  = "request_canceled"
+	codeQueryTimeout     = "query_timeout"
+	codeInternal         = "internal_error"
+)
+
+type problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Index    *int         `json:"index,omitempty"`
+	Errors   []fieldError `json:"errors,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+type requestError struct {
+	part string
+	err  error
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("malformed request %s: %v", e.part, e.err)
+}
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(part string, err error) error {
+	return &requestError{part: part, err: err}
+}
+
+func newProblem(status int, code string, detail string) *problem {
+	title := utils.StatusMessage(status)
+	if status == 499 {
+		title = "Client Closed Request"
+	}
+	return &problem{
+		Type:   "about:blank",
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func errorHandler(lg *zap.SugaredLogger) fiber.ErrorHandler {
+	return func(ctx *fiber.Ctx, err error) error {
+		p := toProblem(err)
+		if p.Status >= 500 && p.Status != 504 {
+			lg.Errorf("request %s %s failed: %v", ctx.Method(), ctx.Path(), err)
+		}
+		p.Instance = ctx.OriginalURL()
+		return ctx.Status(p.Status).JSON(p, problemContentType)
+	}
+}
+
+func toProblem(err error) *problem {
+	var (
+		validationErr *servererrors.ValidationError
+		requestErr    *requestError
+		fiberErr      *fiber.Error
+		batchErr      *servererrors.BatchError
+		periodErr     *servererrors.PeriodError
+	)
+	if errors.As(err, &batchErr) {
+		p := toProblem(batchErr.Err)
+		p.Index = &batchErr.Index
+		return p
+	}
+	if errors.As(err, &periodErr) {
+		p := toProblem(periodErr.Err)
+		p.Errors = []fieldError{{
+			Field:   periodErr.Field,
+			Rule:    "period",
+			Message: periodErr.Err.Error(),
+		}}
+		return p
+	}
+	switch {
+	case errors.As(err, &requestErr):
+		return newProblem(400, codeMalformedRequest, fmt.Sprintf("the request %s could not be parsed", requestErr.part))
+	case errors.As(err, &validationErr):
+		p := newProblem(400, codeValidationFailed, "the request contains invalid fields")
+		p.Errors = fieldErrors(validationErr.Err)
+		if p.Errors == nil {
+			p.Detail = validationErr.Error()
+		}
+		return p
+	case errors.Is(err, servererrors.ErrorRecordNotFound):
+		return newProblem(404, codeNotFound, "the requested record does not exist")
+	case errors.Is(err, servererrors.ErrorServiceNotFound):
+		return newProblem(422, codeServiceNotFound, "the referenced service does not exist")
+	case errors.Is(err, servererrors.ErrorServiceExists):
+		return newProblem(409, codeServiceExists, "a service with this name already exists")
+	case errors.Is(err, servererrors.ErrorServiceInUse):
+		return newProblem(409, codeServiceInUse, "the service has subscriptions that are not deleted and cannot be deleted")
+	case errors.Is(err, servererrors.ErrorInvalidPeriod):
+		return newProblem(422, codeInvalidPeriod, "start_date must not be after stop_date")
+	case errors.Is(err, servererrors.ErrorPeriodTooLong):
+		return newProblem(422, codePeriodTooLong, "the period is longer than allowed")
+	case errors.Is(err, servererrors.ErrorVersionMismatch):
+		return newProblem(412, codeVersionMismatch, "the record has been modified since the version given in If-Match")
+	case errors.Is(err, servererrors.ErrorUnauthenticated):
+		return newProblem(401, codeUnauthenticated, "the request requires a bearer token or an API key")
+	case errors.Is(err, servererrors.ErrorInvalidToken):
+		return newProblem(401, codeInvalidToken, "the bearer token is malformed, expired, without a valid tenant or not signed by a trusted key")
+	case errors.Is(err, servererrors.ErrorInvalidAPIKey):
+		return newProblem(401, codeInvalidAPIKey, "the API key is unknown, expired or revoked")
+	case errors.Is(err, servererrors.ErrorForbidden):
+		return newProblem(403, codeForbidden, "the caller is not allowed to perform the request")
+	case errors.Is(err, servererrors.ErrorInvalidTenant):
+		return newProblem(400, codeInvalidTenant, "the X-Tenant-ID header is not a valid tenant")
+	case errors.Is(err, servererrors.ErrorIdempotencyKeyReused):
+		return newProblem(422, codeKeyReused, "the Idempotency-Key was used with a different request")
+	case errors.Is(err, servererrors.ErrorIdempotencyKeyInProgress):
+		return newProblem(409, codeKeyInProgress, "a request with the Idempotency-Key is still being processed")
+	case errors.Is(err, servererrors.ErrorInvalidCursor):
+		return newProblem(400, codeInvalidCursor, "the cursor is malformed or does not match the requested sort")
+	case errors.Is(err, servererrors.ErrorExchangeRateNotFound):
+		return newProblem(422, codeRateNotFound, "no exchange rate to the requested currency is known for some months of the period")
+	case errors.Is(err, servererrors.ErrorCanceled):
+		return newProblem(499, codeRequestCanceled, "the request was canceled before it completed")
+	case errors.Is(err, servererrors.ErrorTimeout):
+		return newProblem(504, codeQueryTimeout, "the database query did not complete in time")
+	case errors.As(err, &fiberErr):
+		return newProblem(fiberErr.Code, statusCode(fiberErr.Code), fiberErr.Message)
+	}
+	return newProblem(500, codeInternal, "")
+}
+
+func statusCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(utils.StatusMessage(status), " ", "_"))
+}
+
+func fieldErrors(err error) []fieldError {
+	var batchErrs servererrors.BatchErrors
+	if errors.As(err, &batchErrs) {
+		return batchFieldErrors(batchErrs)
+	}
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+	result := make([]fieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		result = append(result, fieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return result
+}
+
+// batchFieldErrors reports the fields of the invalid operations of a batch
+// request prefixed with the operation.
+func batchFieldErrors(errs servererrors.BatchErrors) []fieldError {
+	var result []fieldError
+	for _, batchErr := range errs {
+		prefix := fmt.Sprintf("operations[%d]", batchErr.Index)
+		fields := fieldErrors(batchErr.Err)
+		if fields == nil {
+			result = append(result, fieldError{
+				Field:   prefix,
+				Rule:    "invalid",
+				Message: batchErr.Err.Error(),
+			})
+			continue
+		}
+		for _, field := range fields {
+			field.Field = prefix + "." + field.Field
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s long", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s long", fe.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", fe.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	case "currency":
+		return "must be an ISO 4217 currency code"
+	case "sort":
+		return fmt.Sprintf("must be a comma separated list of distinct fields (prefix with '-' for descending order): %s", fe.Param())
+	}
+	return fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+}
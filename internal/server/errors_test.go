@@ -0,0 +1,26 @@
+package server
+
+import (
+	"fmt"
+	"subscription/internal/pkg/servererrors"
+	"testing"
+)
+
+func TestToProblemPeriod(t *testing.T) {
+	tests := []struct {
+		err      error
+		wantCode string
+	}{
+		{servererrors.ErrorInvalidPeriod, codeInvalidPeriod},
+		{fmt.Errorf("%w: at most 10 years", servererrors.ErrorPeriodTooLong), codePeriodTooLong},
+	}
+	for _, tt := range tests {
+		p := toProblem(&servererrors.PeriodError{Field: "to", Err: tt.err})
+		if p.Status != 422 || p.Code != tt.wantCode {
+			t.Fatalf("%v: got %d %s, want 422 %s", tt.err, p.Status, p.Code, tt.wantCode)
+		}
+		if len(p.Errors) != 1 || p.Errors[0].Field != "to" || p.Errors[0].Message != tt.err.Error() {
+			t.Fatalf("%v: got field errors %+v", tt.err, p.Errors)
+		}
+	}
+}
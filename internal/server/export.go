@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"subscription/internal/model"
+	"subscription/internal/pkg/types"
+	svcDto "subscription/internal/service/dto"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var exportHeader = []string{
+	"subscription_id",
+	"service_id",
+	"service_name",
+	"price",
+	"currency",
+	"user_id",
+	"start_date",
+	"stop_date",
+	"billing_period",
+	"deleted_at",
+}
+
+// ExportSubscriptions streams the subscriptions matching the filters of the
+// list endpoint. The rows are written as they are read, an error after the
+// first row can only cut the response short and is logged.
+func (h *handler) ExportSubscriptions(ctx *fiber.Ctx) error {
+	req := new(svcDto.ExportSubscriptions)
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	// the body is written after the handler returns and the request context
+	// is canceled
+	exportCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx.UserContext()), h.exportTimeout)
+	cursor, err := h.svc.ExportSubscriptions(exportCtx, req)
+	if err != nil {
+		cancel()
+		return err
+	}
+	write, contentType, ext := writeCSV, "text/csv; charset=utf-8", "csv"
+	if req.Format != nil && *req.Format == model.ExportFormatJSONLines {
+		write, contentType, ext = writeJSONLines, "application/jsonl", "jsonl"
+	}
+	ctx.Attachment("subscriptions." + ext)
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Status(200).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		defer cursor.Close()
+		err := write(w, cursor)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			h.lg.Errorf("failed to export subscriptions: %v", err)
+		}
+	})
+	return nil
+}
+
+func writeCSV(w io.Writer, cursor model.SubscriptionCursor) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return err
+	}
+	record := make([]string, len(exportHeader))
+	for cursor.Next() {
+		s := cursor.Subscription()
+		record[0] = strconv.Itoa(s.SubscriptionId)
+		record[1] = strconv.Itoa(s.ServiceId)
+		record[2] = s.ServiceName
+		record[3] = strconv.Itoa(s.Price)
+		record[4] = s.Currency
+		record[5] = s.UserId.String()
+		record[6] = s.StartDate.Format(types.CustomDateFormat)
+		record[7] = ""
+		if s.StopDate != nil {
+			record[7] = s.StopDate.Format(types.CustomDateFormat)
+		}
+		record[8] = s.BillingPeriod
+		record[9] = ""
+		if s.DeletedAt != nil {
+			record[9] = s.DeletedAt.Format(time.RFC3339)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonLine is a subscription of a JSON-lines export, its dates replace the
+// dates of the subscription.
+type jsonLine struct {
+	*model.ExportedSubscription
+	StartDate string  `json:"start_date"`
+	StopDate  *string `json:"stop_date"`
+}
+
+func writeJSONLines(w io.Writer, cursor model.SubscriptionCursor) error {
+	enc := json.NewEncoder(w)
+	for cursor.Next() {
+		s := cursor.Subscription()
+		line := jsonLine{
+			ExportedSubscription: s,
+			StartDate:            s.StartDate.Format(types.CustomDateFormat),
+		}
+		if s.StopDate != nil {
+			stopDate := s.StopDate.Format(types.CustomDateFormat)
+			line.StopDate = &stopDate
+		}
+		if err := enc.Encode(&line); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,427 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/service"
+	svcDto "subscription/internal/service/dto"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	totalCountHeader      = "X-Total-Count"
+	nextCursorHeader      = "X-Next-Cursor"
+	prevCursorHeader      = "X-Prev-Cursor"
+)
+
+type handler struct {
+	svc           service.Service
+	lg            *zap.SugaredLogger
+	exportTimeout time.Duration
+}
+
+func newHandler(svc service.Service, lg *zap.SugaredLogger, exportTimeout time.Duration) *handler {
+	return &handler{
+		svc:           svc,
+		lg:            lg,
+		exportTimeout: exportTimeout,
+	}
+}
+
+func (h *handler) Health(ctx *fiber.Ctx) error {
+	resp := h.svc.Health(ctx.UserContext())
+	if resp.Status != model.HealthStatusOk {
+		return ctx.Status(503).JSON(resp)
+	}
+	return ctx.Status(200).JSON(resp)
+}
+
+func (h *handler) AddService(ctx *fiber.Ctx) error {
+	req := new(svcDto.AddService)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	resp, err := h.svc.AddService(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderETag, etag(resp.Version))
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) GetService(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetService)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	resp, err := h.svc.GetService(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderETag, etag(resp.Version))
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) GetServices(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetServices)
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	resp, err := h.svc.GetServices(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	if err := setPageHeaders(ctx, resp.Total, resp.NextCursor, resp.PrevCursor); err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp.Items)
+}
+func (h *handler) UpdateService(ctx *fiber.Ctx) error {
+	req := new(svcDto.UpdateService)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	req.Versions = ifMatch(ctx)
+	if err := h.svc.UpdateService(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+func (h *handler) PatchService(ctx *fiber.Ctx) error {
+	req := new(svcDto.PatchService)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	patch, err := mergePatch(ctx)
+	if err != nil {
+		return err
+	}
+	req.Patch = patch
+	req.Versions = ifMatch(ctx)
+	if err := h.svc.PatchService(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+func (h *handler) RemoveService(ctx *fiber.Ctx) error {
+	req := new(svcDto.RemoveService)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	req.Versions = ifMatch(ctx)
+	if err := h.svc.RemoveService(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+
+func (h *handler) RestoreService(ctx *fiber.Ctx) error {
+	req := new(svcDto.RestoreService)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	if err := h.svc.RestoreService(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+
+func (h *handler) AddSubscription(ctx *fiber.Ctx) error {
+	req := new(svcDto.AddSubscription)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	resp, err := h.svc.AddSubscription(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderETag, etag(resp.Version))
+	return ctx.Status(201).JSON(resp)
+}
+func (h *handler) GetSubscription(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetSubscription)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	resp, err := h.svc.GetSubscription(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderETag, etag(resp.Version))
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) GetSubscriptions(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetSubscriptions)
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	resp, err := h.svc.GetSubscriptions(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	if err := setPageHeaders(ctx, resp.Total, resp.NextCursor, resp.PrevCursor); err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp.Items)
+}
+func (h *handler) GetSubscriptionTotal(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetSubscriptionTotal)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	resp, err := h.svc.GetSubscriptionTotal(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) GetSubscriptionTotalQuery(ctx *fiber.Ctx) error {
+	query := new(svcDto.GetSubscriptionTotalQuery)
+	if err := ctx.QueryParser(query); err != nil {
+		return badRequest("query parameters", err)
+	}
+
+	// the caller is authorized and the query validated before a cached
+	// total is confirmed
+	revision, err := h.svc.GetSubscriptionTotalRevision(ctx.UserContext(), query)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	// the report is scoped to the caller
+	ctx.Vary(fiber.HeaderAuthorization, apiKeyHeader, tenantHeader)
+	ctx.Set(fiber.HeaderETag, etag(revision.Revision))
+	ctx.Set(fiber.HeaderLastModified, revision.ModifiedAt.UTC().Format(http.TimeFormat))
+	if ctx.Fresh() {
+		return ctx.SendStatus(304)
+	}
+
+	resp, err := h.svc.GetSubscriptionTotal(ctx.UserContext(), &svcDto.GetSubscriptionTotal{
+		StartDate:      query.From,
+		StopDate:       query.To,
+		UserId:         query.UserId,
+		ServiceName:    query.ServiceName,
+		GroupBy:        query.GroupBy,
+		Prorate:        query.Prorate,
+		Currency:       query.Currency,
+		IncludeDeleted: query.IncludeDeleted,
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) ImportExchangeRates(ctx *fiber.Ctx) error {
+	imported, err := h.svc.ImportExchangeRates(ctx.UserContext(), bytes.NewReader(ctx.Body()))
+	if err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(fiber.Map{"imported": imported})
+}
+
+// ImportSubscriptions reads the CSV file from the body or from the file field
+// of a multipart form.
+func (h *handler) ImportSubscriptions(ctx *fiber.Ctx) error {
+	req := new(svcDto.ImportSubscriptions)
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	var body io.Reader = bytes.NewReader(ctx.Body())
+	if strings.HasPrefix(ctx.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			return badRequest("body", err)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return badRequest("body", err)
+		}
+		defer file.Close()
+		body = file
+	}
+	resp, err := h.svc.ImportSubscriptions(ctx.UserContext(), req, body)
+	if err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) UpdateSubscription(ctx *fiber.Ctx) error {
+	req := new(svcDto.UpdateSubscription)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	req.Versions = ifMatch(ctx)
+	if err := h.svc.UpdateSubscription(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+func (h *handler) PatchSubscription(ctx *fiber.Ctx) error {
+	req := new(svcDto.PatchSubscription)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	patch, err := mergePatch(ctx)
+	if err != nil {
+		return err
+	}
+	req.Patch = patch
+	req.Versions = ifMatch(ctx)
+	if err := h.svc.PatchSubscription(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+func (h *handler) GetSubscriptionPrices(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetSubscriptionPrices)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	resp, err := h.svc.GetSubscriptionPrices(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp)
+}
+func (h *handler) RemoveSubscription(ctx *fiber.Ctx) error {
+	req := new(svcDto.RemoveSubscription)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	req.Versions = ifMatch(ctx)
+	if err := h.svc.RemoveSubscription(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+
+func (h *handler) RestoreSubscription(ctx *fiber.Ctx) error {
+	req := new(svcDto.RestoreSubscription)
+	if err := ctx.ParamsParser(req); err != nil {
+		return badRequest("path parameters", err)
+	}
+	if err := h.svc.RestoreSubscription(ctx.UserContext(), req); err != nil {
+		return err
+	}
+	return ctx.SendStatus(204)
+}
+
+type batchResult struct {
+	Index        int                 `json:"index"`
+	Op           string              `json:"op"`
+	Status       int                 `json:"status"`
+	Subscription *model.Subscription `json:"subscription,omitempty"`
+	Error        *problem            `json:"error,omitempty"`
+}
+
+func (h *handler) BatchSubscriptions(ctx *fiber.Ctx) error {
+	req := new(svcDto.BatchSubscriptions)
+	if err := ctx.BodyParser(req); err != nil {
+		return badRequest("body", err)
+	}
+	results, err := h.svc.BatchSubscriptions(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	resp := make([]*batchResult, len(results))
+	for i, result := range results {
+		resp[i] = &batchResult{
+			Index:        result.Index,
+			Op:           result.Operation,
+			Status:       204,
+			Subscription: result.Subscription,
+		}
+		switch {
+		case result.Err != nil:
+			resp[i].Error = toProblem(result.Err)
+			resp[i].Status = resp[i].Error.Status
+		case result.Operation == model.BatchOperationCreate:
+			resp[i].Status = 201
+		}
+	}
+	return ctx.Status(200).JSON(fiber.Map{"results": resp})
+}
+func (h *handler) GetAuditLog(ctx *fiber.Ctx) error {
+	req := new(svcDto.GetAuditLog)
+	if err := ctx.QueryParser(req); err != nil {
+		return badRequest("query parameters", err)
+	}
+	resp, err := h.svc.GetAuditLog(ctx.UserContext(), req)
+	if err != nil {
+		return err
+	}
+	if err := setPageHeaders(ctx, resp.Total, resp.NextCursor, resp.PrevCursor); err != nil {
+		return err
+	}
+	return ctx.Status(200).JSON(resp.Items)
+}
+
+// setPageHeaders describes a page of a list returned as a bare array: the
+// X-Total-Count header holds the number of matching records, the
+// X-Next-Cursor and X-Prev-Cursor headers the cursors of the next and
+// previous pages and the Link header their URLs.
+func setPageHeaders(ctx *fiber.Ctx, total int, next string, prev string) error {
+	ctx.Set(totalCountHeader, strconv.Itoa(total))
+	if next != "" {
+		ctx.Set(nextCursorHeader, next)
+	}
+	if prev != "" {
+		ctx.Set(prevCursorHeader, prev)
+	}
+	query, err := url.ParseQuery(string(ctx.Request().URI().QueryString()))
+	if err != nil {
+		return badRequest("query parameters", err)
+	}
+	query.Del("offset")
+	links := []string{}
+	for _, link := range []struct{ rel, cursor string }{{"next", next}, {"prev", prev}} {
+		if link.cursor == "" {
+			continue
+		}
+		query.Set("cursor", link.cursor)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, ctx.Path(), query.Encode(), link.rel))
+	}
+	if len(links) > 0 {
+		ctx.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+	return nil
+}
+
+// mergePatch returns the JSON Merge Patch sent as the request body.
+func mergePatch(ctx *fiber.Ctx) ([]byte, error) {
+	contentType := utils.ToLower(utils.UnsafeString(ctx.Request().Header.ContentType()))
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType != mergePatchContentType && mediaType != fiber.MIMEApplicationJSON {
+		return nil, fiber.ErrUnsupportedMediaType
+	}
+	body := ctx.Body()
+	if !json.Valid(body) {
+		return nil, badRequest("body", errors.New("invalid JSON"))
+	}
+	return slices.Clone(body), nil
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/service"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyStoreTimeout  = 5 * time.Second
+)
+
+// replayedHeaders are the headers of a response stored with it besides its
+// content type.
+var replayedHeaders = []string{fiber.HeaderETag, fiber.HeaderLocation, fiber.HeaderLastModified}
+
+// idempotency replays the stored response of a request repeated with the
+// same Idempotency-Key header instead of handling it again. Responses with a
+// 5xx status aren't stored so that the request can be retried. A request
+// holds its key for the lease, the longest it may be handled, a repeated
+// request takes over the key of a request that didn't complete in time.
+func idempotency(svc service.Service, lg *zap.SugaredLogger, ttl time.Duration, lease time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(idempotencyKeyHeader)
+		if key == "" {
+			return ctx.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return &servererrors.ValidationError{Err: errors.New("Idempotency-Key must be at most 255 characters long")}
+		}
+
+		hash, err := fingerprint(ctx)
+		if err != nil {
+			return badRequest("body", err)
+		}
+		stored, err := svc.ClaimIdempotencyKey(ctx.UserContext(), key, hash, ttl, lease)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			ctx.Set(idempotentReplayedHeader, "true")
+			if stored.ContentType != "" {
+				ctx.Set(fiber.HeaderContentType, stored.ContentType)
+			}
+			for name, value := range stored.Headers {
+				ctx.Set(name, value)
+			}
+			return ctx.Status(stored.Status).Send(stored.Body)
+		}
+
+		if err := ctx.Next(); err != nil {
+			if err := ctx.App().ErrorHandler(ctx, err); err != nil {
+				return err
+			}
+		}
+		resp := &model.IdempotentResponse{
+			Status:      ctx.Response().StatusCode(),
+			ContentType: string(ctx.Response().Header.ContentType()),
+			Headers:     map[string]string{},
+			Body:        slices.Clone(ctx.Response().Body()),
+		}
+		for _, name := range replayedHeaders {
+			if value := ctx.Response().Header.Peek(name); len(value) > 0 {
+				resp.Headers[name] = string(value)
+			}
+		}
+		// the request context may be done already, its actor and tenant are kept
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx.UserContext()), idempotencyStoreTimeout)
+		defer cancel()
+		if resp.Status >= 500 {
+			if err := svc.ReleaseIdempotencyKey(storeCtx, key); err != nil {
+				lg.Errorf("failed to release idempotency key: %v", err)
+			}
+			return nil
+		}
+		if err := svc.CompleteIdempotencyKey(storeCtx, key, resp); err != nil {
+			lg.Errorf("failed to store idempotent response: %v", err)
+		}
+		return nil
+	}
+}
+
+// fingerprint identifies a request by its method, path, query and body. The
+// query parameters are sorted, a multipart body is identified by its fields
+// and files since its boundary is random.
+func fingerprint(ctx *fiber.Ctx) (string, error) {
+	query, err := url.ParseQuery(string(ctx.Request().URI().QueryString()))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", ctx.Method(), ctx.Path(), query.Encode())
+	if !strings.HasPrefix(ctx.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		h.Write(ctx.Body())
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%d\n", len(form.Value))
+	for _, name := range slices.Sorted(maps.Keys(form.Value)) {
+		fmt.Fprintf(h, "%q %q\n", name, form.Value[name])
+	}
+	fmt.Fprintf(h, "%d\n", len(form.File))
+	for _, name := range slices.Sorted(maps.Keys(form.File)) {
+		for _, header := range form.File[name] {
+			fmt.Fprintf(h, "%q %q %d\n", name, header.Filename, header.Size)
+			file, err := header.Open()
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(h, file)
+			file.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"reflect"
+	"subscription/internal/pkg/types"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func init() {
+	fiber.SetParserDecoder(fiber.ParserConfig{
+		IgnoreUnknownKeys: true,
+		ZeroEmpty:         true,
+		ParserType: []fiber.ParserType{
+			{Customtype: uuid.UUID{}, Converter: convertUUID},
+			{Customtype: types.CustomDate{}, Converter: convertCustomDate},
+		},
+	})
+}
+
+func convertUUID(value string) reflect.Value {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(id)
+}
+func convertCustomDate(value string) reflect.Value {
+	date, err := types.ParseCustomDate(value)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(date)
+}
@@ -3,6 +3,9 @@ package server
 import (
 	"context"
 	"subscription/internal/config"
+	"subscription/internal/pkg/actor"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
 	"subscription/internal/service"
 	"time"
 
@@ -12,41 +15,123 @@ import (
 	"go.uber.org/zap"
 )
 
+// actorHeader names the client making a change when authentication is
+// disabled, it is recorded in the audit log.
+const actorHeader = "X-Actor"
+
+// tenantHeader names the tenant of a request when authentication is disabled.
+const tenantHeader = "X-Tenant-ID"
+
+// shutdownTimeout is the time the requests in progress have to complete on
+// shutdown before their queries are canceled.
+const shutdownTimeout = 10 * time.Second
+
 type Server struct {
 	app      *fiber.App
 	bindAddr string
 	lg       *zap.SugaredLogger
+	// cancel cancels the contexts of the requests in progress
+	cancel context.CancelFunc
 }
 
 func New(svc service.Service, lg *zap.SugaredLogger, cfg *config.Srv) *Server {
 	app := fiber.New(fiber.Config{
 		AppName:      cfg.AppName,
 		WriteTimeout: cfg.WriteTimeout,
+		ErrorHandler: errorHandler(lg),
 	})
 	app.Use(recover.New(recover.ConfigDefault))
 	app.Use(fiberzap.New(fiberzap.Config{
 		Logger: lg.Desugar(),
 	}))
+	// fasthttp doesn't report a client that disconnects, so the queries of a
+	// request are canceled only by the write timeout or by a shutdown that
+	// times out. The context isn't derived from the request context as
+	// fasthttp reuses it for later requests.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	app.Use(func(ctx *fiber.Ctx) error {
+		userCtx, cancel := context.WithTimeout(baseCtx, cfg.WriteTimeout)
+		defer cancel()
+		ctx.SetUserContext(userCtx)
+		return ctx.Next()
+	})
+	// the actor and the tenant are taken from the token unless authentication
+	// is disabled
+	authMiddleware := func(ctx *fiber.Ctx) error {
+		tenantId := cfg.DefaultTenant
+		if id := ctx.Get(tenantHeader); id != "" {
+			if !tenant.Valid(id) {
+				return servererrors.ErrorInvalidTenant
+			}
+			tenantId = id
+		}
+		userCtx := tenant.NewContext(ctx.UserContext(), tenantId)
+		if name := ctx.Get(actorHeader); name != "" {
+			userCtx = actor.NewContext(userCtx, name)
+		}
+		ctx.SetUserContext(userCtx)
+		return ctx.Next()
+	}
+	if cfg.AuthDisabled {
+		if !tenant.Valid(cfg.DefaultTenant) {
+			lg.Fatalf("invalid default tenant %q", cfg.DefaultTenant)
+		}
+		lg.Warn("authentication is disabled")
+	} else {
+		verifier, err := newVerifier(cfg)
+		if err != nil {
+			lg.Fatalf("failed to configure authentication: %v", err)
+		}
+		authMiddleware = authenticate(svc, verifier, lg)
+	}
+
+	// an export lasts no longer than the server may spend writing the response
+	h := newHandler(svc, lg, cfg.WriteTimeout)
+	idem := idempotency(svc, lg, cfg.IdempotencyTTL, cfg.WriteTimeout)
+
+	app.Get("/health", h.Health)
+
+	// version 2 of the API has prices in minor units of the currency
+	appGroup := app.Group("/api/v2", authMiddleware)
+
+	appGroup.Post("/services", idem, h.AddService)
+	appGroup.Get("/services/:id", h.GetService)
+	appGroup.Get("/services", h.GetServices)
+	appGroup.Put("/services/:id", h.UpdateService)
+	appGroup.Patch("/services/:id", h.PatchService)
+	appGroup.Delete("/services/:id", h.RemoveService)
+	appGroup.Post("/services/:id/restore", h.RestoreService)
+
+	appGroup.Post("/subscriptions", idem, h.AddSubscription)
+	appGroup.Post("/subscriptions/batch", idem, h.BatchSubscriptions)
+	appGroup.Post("/subscriptions/import", idem, h.ImportSubscriptions)
+	appGroup.Get("/subscriptions/total", h.GetSubscriptionTotalQuery)
+	appGroup.Get("/subscriptions/export", h.ExportSubscriptions)
+	appGroup.Get("/subscriptions/:id", h.GetSubscription)
+	appGroup.Get("/subscriptions/:id/prices", h.GetSubscriptionPrices)
+	appGroup.Get("/subscriptions", h.GetSubscriptions)
+	appGroup.Post("/subscriptions/total", h.GetSubscriptionTotal)
+	appGroup.Put("/subscriptions/:id", h.UpdateSubscription)
+	appGroup.Patch("/subscriptions/:id", h.PatchSubscription)
+	appGroup.Delete("/subscriptions/:id", h.RemoveSubscription)
+	appGroup.Post("/subscriptions/:id/restore", h.RestoreSubscription)
 
-	appGroup := app.Group("/api/v1")
+	appGroup.Post("/exchange-rates", idem, h.ImportExchangeRates)
 
-	appGroup.Post("/services", svc.AddService)
-	appGroup.Get("/services/:id", svc.GetService)
-	appGroup.Get("/services", svc.GetServices)
-	appGroup.Put("/services/:id", svc.UpdateService)
-	appGroup.Delete("/services/:id", svc.RemoveService)
+	appGroup.Get("/audit", h.GetAuditLog)
 
-	appGroup.Post("/subscriptions", svc.AddSubscription)
-	appGroup.Get("/subscriptions/:id", svc.GetSubscription)
-	appGroup.Get("/subscriptions", svc.GetSubscriptions)
-	appGroup.Post("/subscriptions/total", svc.GetSubscriptionTotal)
-	appGroup.Put("/subscriptions/:id", svc.UpdateSubscription)
-	appGroup.Delete("/subscriptions/:id", svc.RemoveSubscription)
+	// the responses with a new key aren't stored, the key would be kept in
+	// clear text
+	appGroup.Post("/api-keys", h.AddAPIKey)
+	appGroup.Get("/api-keys", h.GetAPIKeys)
+	appGroup.Post("/api-keys/:id/rotate", h.RotateAPIKey)
+	appGroup.Delete("/api-keys/:id", h.RevokeAPIKey)
 
 	return &Server{
 		app:      app,
 		bindAddr: cfg.Addr,
 		lg:       lg,
+		cancel:   cancelBase,
 	}
 
 }
@@ -61,8 +146,9 @@ func (s *Server) Start() {
 
 }
 func (s *Server) Stop() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
+	defer s.cancel()
 	if err := s.app.ShutdownWithContext(ctx); err != nil {
 		s.lg.Errorf("failed to shutdown server: %v", err)
 		return
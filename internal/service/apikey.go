@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/pkg/auth"
+	"subscription/internal/pkg/servererrors"
+	repoDto "subscription/internal/repository/dto"
+	svcDto "subscription/internal/service/dto"
+	"time"
+)
+
+const (
+	apiKeyPrefix = "sk_"
+	// apiKeyHintLength is the length of the beginning of a key kept to
+	// recognize it in the list of keys.
+	apiKeyHintLength = len(apiKeyPrefix) + 8
+)
+
+// newAPIKey generates a key and returns it with its hint and hash, the key
+// has enough entropy to be stored as a plain SHA-256 hash.
+func newAPIKey() (string, string, string) {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:apiKeyHintLength], hashAPIKey(key)
+}
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *service) AddAPIKey(ctx context.Context, req *svcDto.AddAPIKey) (*model.NewAPIKey, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, &servererrors.ValidationError{Err: errors.New("expires_at must be in the future")}
+	}
+	key, prefix, hash := newAPIKey()
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	apiKey, err := s.repo.AddAPIKey(queryCtx, &repoDto.AddAPIKey{
+		Name:      *req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.NewAPIKey{APIKey: *apiKey, Key: key}, nil
+}
+func (s *service) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.GetAPIKeys(queryCtx)
+}
+func (s *service) RotateAPIKey(ctx context.Context, req *svcDto.RotateAPIKey) (*model.NewAPIKey, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	key, prefix, hash := newAPIKey()
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	apiKey, err := s.repo.RotateAPIKey(queryCtx, &repoDto.RotateAPIKey{
+		APIKeyId: *req.APIKeyId,
+		Prefix:   prefix,
+		KeyHash:  hash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.NewAPIKey{APIKey: *apiKey, Key: key}, nil
+}
+func (s *service) RevokeAPIKey(ctx context.Context, req *svcDto.RevokeAPIKey) error {
+	if err := validate(req); err != nil {
+		return err
+	}
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RevokeAPIKey(queryCtx, *req.APIKeyId)
+}
+
+// AuthenticateAPIKey returns the principal of an active key, the key is
+// recorded as the actor of the changes made with it.
+func (s *service) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, servererrors.ErrorInvalidAPIKey
+	}
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	apiKey, err := s.repo.AuthenticateAPIKey(queryCtx, hashAPIKey(key))
+	if errors.Is(err, servererrors.ErrorRecordNotFound) {
+		return nil, servererrors.ErrorInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	principal := &auth.Principal{
+		Subject: fmt.Sprintf("api-key:%d", apiKey.APIKeyId),
+		Tenant:  apiKey.TenantId,
+		APIKey:  true,
+		Scopes:  apiKey.Scopes,
+	}
+	if apiKey.ExpiresAt != nil {
+		principal.ExpiresAt = *apiKey.ExpiresAt
+	}
+	return principal, nil
+}
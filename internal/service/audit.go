@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	repoDto "subscription/internal/repository/dto"
+	svcDto "subscription/internal/service/dto"
+)
+
+// auditSort lists the newest changes first.
+var auditSort = "-audit_id"
+
+func (s *service) GetAuditLog(ctx context.Context, req *svcDto.GetAuditLog) (*model.AuditList, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if req.EntityId != nil && req.Entity == nil {
+		return nil, &servererrors.ValidationError{Err: errors.New("entity is required to filter by id")}
+	}
+	p, err := newPage(&auditSort, "audit_id", req.Cursor, req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	entries, total, err := s.repo.GetAuditLog(queryCtx, &repoDto.GetAuditLog{
+		Filter: repoDto.AuditFilter{
+			Entity:    req.Entity,
+			EntityId:  req.EntityId,
+			Actor:     req.Actor,
+			Operation: req.Operation,
+			From:      req.From,
+			To:        req.To,
+		},
+		Sort:   p.sort,
+		Keyset: p.keyset,
+		Offset: p.offset,
+		Limit:  p.limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, next, prev := paginate(p, entries, auditKey)
+	return &model.AuditList{
+		Items:      entries,
+		Total:      total,
+		Offset:     p.offset,
+		Limit:      p.limit,
+		NextCursor: next,
+		PrevCursor: prev,
+	}, nil
+}
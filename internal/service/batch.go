@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	repoDto "subscription/internal/repository/dto"
+	svcDto "subscription/internal/service/dto"
+)
+
+// BatchSubscriptions runs the operations of req in one transaction. An atomic
+// batch, the default, is rejected as a whole when any operation is invalid or
+// fails, otherwise the result of every operation is returned in order.
+func (s *service) BatchSubscriptions(ctx context.Context, req *svcDto.BatchSubscriptions) ([]*model.BatchResult, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	atomic := req.Atomic == nil || *req.Atomic
+	ops := make([]*repoDto.SubscriptionOperation, 0, len(req.Operations))
+	var (
+		invalid []*model.BatchResult
+		errs    servererrors.BatchErrors
+	)
+	for i, op := range req.Operations {
+		repoOp, err := batchOperation(i, op)
+		if err != nil {
+			invalid = append(invalid, &model.BatchResult{Index: i, Operation: *op.Op, Err: err})
+			errs = append(errs, &servererrors.BatchError{Index: i, Err: err})
+			continue
+		}
+		ops = append(ops, repoOp)
+	}
+	if atomic && len(errs) > 0 {
+		return nil, &servererrors.ValidationError{Err: errs}
+	}
+
+	results := invalid
+	if len(ops) > 0 {
+		queryCtx, cancel := s.queryContext(ctx)
+		defer cancel()
+		done, err := s.repo.BatchSubscriptions(queryCtx, ops, atomic)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, done...)
+	}
+	slices.SortFunc(results, func(a, b *model.BatchResult) int {
+		return a.Index - b.Index
+	})
+	return results, nil
+}
+
+func batchOperation(index int, op *svcDto.BatchSubscriptionOperation) (*repoDto.SubscriptionOperation, error) {
+	var versions []int64
+	if op.Version != nil {
+		versions = []int64{*op.Version}
+	}
+	subscription := []byte(op.Subscription)
+	if len(subscription) == 0 {
+		subscription = []byte("{}")
+	}
+	switch *op.Op {
+	case model.BatchOperationCreate:
+		if op.SubscriptionId != nil || op.Version != nil {
+			return nil, &servererrors.ValidationError{Err: errors.New("id and version must not be given to create a subscription")}
+		}
+		req := new(svcDto.AddSubscription)
+		if err := decodeRecord(subscription, req); err != nil {
+			return nil, err
+		}
+		if err := validate(req); err != nil {
+			return nil, err
+		}
+		return &repoDto.SubscriptionOperation{Index: index, Create: addSubscriptionDto(req)}, nil
+	case model.BatchOperationUpdate:
+		req := new(svcDto.UpdateSubscription)
+		if err := decodeRecord(subscription, req); err != nil {
+			return nil, err
+		}
+		req.SubscriptionId = op.SubscriptionId
+		req.Versions = versions
+		if err := validate(req); err != nil {
+			return nil, err
+		}
+		return &repoDto.SubscriptionOperation{Index: index, Update: updateSubscriptionDto(req)}, nil
+	}
+	if op.Subscription != nil {
+		return nil, &servererrors.ValidationError{Err: errors.New("subscription must not be given to delete a subscription")}
+	}
+	req := &svcDto.RemoveSubscription{SubscriptionId: op.SubscriptionId, Versions: versions}
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	return &repoDto.SubscriptionOperation{Index: index, Remove: &repoDto.RemoveSubscription{
+		SubscriptionId: *req.SubscriptionId,
+		Versions:       req.Versions,
+	}}, nil
+}
@@ -1,7 +1,9 @@
 package dto
 
 import (
+	"encoding/json"
 	"subscription/internal/pkg/types"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -10,46 +12,176 @@ type AddService struct {
 	Name *string `json:"name" validate:"required,min=1"`
 }
 type GetService struct {
-	ServiceId *int `params:"id" validate:"required,gte=1"`
+	ServiceId      *int  `params:"id" validate:"required,gte=1"`
+	IncludeDeleted *bool `query:"include_deleted" validate:"omitempty"`
+}
+type GetServices struct {
+	Offset         *int    `query:"offset" validate:"omitempty,gte=0"`
+	Limit          *int    `query:"limit" validate:"omitempty,gte=0,lte=100"`
+	Cursor         *string `query:"cursor" validate:"omitempty,min=1"`
+	IncludeDeleted *bool   `query:"include_deleted" validate:"omitempty"`
 }
 type UpdateService struct {
 	ServiceId *int    `params:"id" validate:"required,gte=1"`
 	Name      *string `json:"name" validate:"required,min=1"`
+	Versions  []int64 `json:"-"`
+}
+type PatchService struct {
+	ServiceId *int    `params:"id" validate:"required,gte=1"`
+	Patch     []byte  `json:"-"`
+	Versions  []int64 `json:"-"`
 }
 type RemoveService struct {
+	ServiceId *int    `params:"id" validate:"required,gte=1"`
+	Versions  []int64 `json:"-"`
+}
+type RestoreService struct {
 	ServiceId *int `params:"id" validate:"required,gte=1"`
 }
 
 type AddSubscription struct {
-	ServiceName *string           `json:"service_name" validate:"required,min=1"`
-	Price       *int              `json:"price" validate:"required,gte=0"`
-	UserId      *uuid.UUID        `json:"user_id" validate:"required"`
-	StartDate   *types.CustomDate `json:"start_date" validate:"required"`
-	StopDate    *types.CustomDate `json:"stop_date" validate:"omitempty"`
+	ServiceName   *string           `json:"service_name" validate:"required,min=1"`
+	Price         *int              `json:"price" validate:"required,gte=0"`
+	Currency      *string           `json:"currency" validate:"omitempty,currency"`
+	UserId        *uuid.UUID        `json:"user_id" validate:"required"`
+	StartDate     *types.CustomDate `json:"start_date" validate:"required"`
+	StopDate      *types.CustomDate `json:"stop_date" validate:"omitempty"`
+	BillingPeriod *string           `json:"billing_period" validate:"omitempty,oneof=weekly monthly quarterly yearly"`
 }
 
 type GetSubscription struct {
-	SubscriptionId *int `params:"id" validate:"required,gte=1"`
+	SubscriptionId *int  `params:"id" validate:"required,gte=1"`
+	IncludeDeleted *bool `query:"include_deleted" validate:"omitempty"`
 }
 type GetSubscriptions struct {
-	Offset *int `query:"offset" validate:"omitempty,gte=0"`
-	Limit  *int `query:"limit" validate:"omitempty,gte=0"`
+	Offset         *int              `query:"offset" validate:"omitempty,gte=0"`
+	Limit          *int              `query:"limit" validate:"omitempty,gte=0,lte=100"`
+	Cursor         *string           `query:"cursor" validate:"omitempty,min=1"`
+	UserId         *uuid.UUID        `query:"user_id" validate:"omitempty"`
+	ServiceId      *int              `query:"service_id" validate:"omitempty,gte=1"`
+	ServiceName    *string           `query:"service_name" validate:"omitempty,min=1"`
+	Search         *string           `query:"search" validate:"omitempty,min=1"`
+	PriceMin       *int              `query:"price_min" validate:"omitempty,gte=0"`
+	PriceMax       *int              `query:"price_max" validate:"omitempty,gte=0"`
+	ActiveOn       *types.CustomDate `query:"active_on" validate:"omitempty"`
+	StartFrom      *types.CustomDate `query:"start_from" validate:"omitempty"`
+	StartTo        *types.CustomDate `query:"start_to" validate:"omitempty"`
+	StopFrom       *types.CustomDate `query:"stop_from" validate:"omitempty"`
+	StopTo         *types.CustomDate `query:"stop_to" validate:"omitempty"`
+	OpenEnded      *bool             `query:"open_ended" validate:"omitempty"`
+	IncludeDeleted *bool             `query:"include_deleted" validate:"omitempty"`
+	Sort           *string           `query:"sort" validate:"omitempty,sort=subscription_id service_id price user_id start_date stop_date"`
+}
+type ExportSubscriptions struct {
+	Format         *string           `query:"format" validate:"omitempty,oneof=csv jsonl"`
+	UserId         *uuid.UUID        `query:"user_id" validate:"omitempty"`
+	ServiceId      *int              `query:"service_id" validate:"omitempty,gte=1"`
+	ServiceName    *string           `query:"service_name" validate:"omitempty,min=1"`
+	Search         *string           `query:"search" validate:"omitempty,min=1"`
+	PriceMin       *int              `query:"price_min" validate:"omitempty,gte=0"`
+	PriceMax       *int              `query:"price_max" validate:"omitempty,gte=0"`
+	ActiveOn       *types.CustomDate `query:"active_on" validate:"omitempty"`
+	StartFrom      *types.CustomDate `query:"start_from" validate:"omitempty"`
+	StartTo        *types.CustomDate `query:"start_to" validate:"omitempty"`
+	StopFrom       *types.CustomDate `query:"stop_from" validate:"omitempty"`
+	StopTo         *types.CustomDate `query:"stop_to" validate:"omitempty"`
+	OpenEnded      *bool             `query:"open_ended" validate:"omitempty"`
+	IncludeDeleted *bool             `query:"include_deleted" validate:"omitempty"`
+	Sort           *string           `query:"sort" validate:"omitempty,sort=subscription_id service_id price user_id start_date stop_date"`
 }
 type GetSubscriptionTotal struct {
-	StartDate   *types.CustomDate `json:"start_date" validate:"required"`
-	StopDate    *types.CustomDate `json:"stop_date" validate:"required"`
-	UserId      *uuid.UUID        `json:"user_id" validate:"omitempty"`
-	ServiceName *string           `json:"service_name" validate:"omitempty,min=1"`
+	StartDate      *types.CustomDate `json:"start_date" validate:"required"`
+	StopDate       *types.CustomDate `json:"stop_date" validate:"required"`
+	UserId         *uuid.UUID        `json:"user_id" validate:"omitempty"`
+	ServiceName    *string           `json:"service_name" validate:"omitempty,min=1"`
+	GroupBy        []string          `json:"group_by" validate:"omitempty,dive,oneof=service user"`
+	Prorate        *bool             `json:"prorate" validate:"omitempty"`
+	Currency       *string           `json:"currency" validate:"omitempty,currency"`
+	IncludeDeleted *bool             `json:"include_deleted" validate:"omitempty"`
+}
+
+type GetSubscriptionTotalQuery struct {
+	From           *types.CustomDate `query:"from" validate:"required"`
+	To             *types.CustomDate `query:"to" validate:"required"`
+	UserId         *uuid.UUID        `query:"user_id" validate:"omitempty"`
+	ServiceName    *string           `query:"service_name" validate:"omitempty,min=1"`
+	GroupBy        []string          `query:"group_by" validate:"omitempty,dive,oneof=service user"`
+	Prorate        *bool             `query:"prorate" validate:"omitempty"`
+	Currency       *string           `query:"currency" validate:"omitempty,currency"`
+	IncludeDeleted *bool             `query:"include_deleted" validate:"omitempty"`
+}
+
+type ImportExchangeRate struct {
+	BaseCurrency  string            `json:"base_currency" validate:"currency"`
+	QuoteCurrency string            `json:"quote_currency" validate:"currency,nefield=BaseCurrency"`
+	EffectiveDate *types.CustomDate `json:"effective_date" validate:"required"`
+	Rate          *float64          `json:"rate" validate:"required,gt=0"`
 }
 
 type UpdateSubscription struct {
-	SubscriptionId *int              `params:"id" validate:"required,gte=1"`
-	ServiceName    *string           `json:"service_name" validate:"required,min=1"`
-	Price          *int              `json:"price" validate:"required,gte=0"`
-	UserId         *uuid.UUID        `json:"user_id" validate:"required"`
-	StartDate      *types.CustomDate `json:"start_date" validate:"required"`
-	StopDate       *types.CustomDate `json:"stop_date,omitempty"`
+	SubscriptionId     *int              `params:"id" validate:"required,gte=1"`
+	ServiceName        *string           `json:"service_name" validate:"required,min=1"`
+	Price              *int              `json:"price" validate:"required,gte=0"`
+	PriceEffectiveDate *types.CustomDate `json:"price_effective_date" validate:"omitempty"`
+	UserId             *uuid.UUID        `json:"user_id" validate:"required"`
+	StartDate          *types.CustomDate `json:"start_date" validate:"required"`
+	StopDate           *types.CustomDate `json:"stop_date,omitempty"`
+	BillingPeriod      *string           `json:"billing_period" validate:"omitempty,oneof=weekly monthly quarterly yearly"`
+	Currency           *string           `json:"currency" validate:"omitempty,currency"`
+	Versions           []int64           `json:"-"`
+}
+type PatchSubscription struct {
+	SubscriptionId *int    `params:"id" validate:"required,gte=1"`
+	Patch          []byte  `json:"-"`
+	Versions       []int64 `json:"-"`
+}
+type GetSubscriptionPrices struct {
+	SubscriptionId *int `params:"id" validate:"required,gte=1"`
 }
 type RemoveSubscription struct {
+	SubscriptionId *int    `params:"id" validate:"required,gte=1"`
+	Versions       []int64 `json:"-"`
+}
+type RestoreSubscription struct {
 	SubscriptionId *int `params:"id" validate:"required,gte=1"`
 }
+
+type ImportSubscriptions struct {
+	DryRun         *bool `query:"dry_run" validate:"omitempty"`
+	CreateServices *bool `query:"create_services" validate:"omitempty"`
+}
+
+type BatchSubscriptions struct {
+	Atomic     *bool                         `json:"atomic"`
+	Operations []*BatchSubscriptionOperation `json:"operations" validate:"required,min=1,max=1000,dive,required"`
+}
+type BatchSubscriptionOperation struct {
+	Op             *string         `json:"op" validate:"required,oneof=create update delete"`
+	SubscriptionId *int            `json:"id"`
+	Version        *int64          `json:"version"`
+	Subscription   json.RawMessage `json:"subscription"`
+}
+
+type AddAPIKey struct {
+	Name      *string    `json:"name" validate:"required,min=1,max=255"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read:services write:services read:subscriptions write:subscriptions reports"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty"`
+}
+type RotateAPIKey struct {
+	APIKeyId *int64 `params:"id" validate:"required,gte=1"`
+}
+type RevokeAPIKey struct {
+	APIKeyId *int64 `params:"id" validate:"required,gte=1"`
+}
+
+type GetAuditLog struct {
+	Offset    *int              `query:"offset" validate:"omitempty,gte=0"`
+	Limit     *int              `query:"limit" validate:"omitempty,gte=0,lte=100"`
+	Cursor    *string           `query:"cursor" validate:"omitempty,min=1"`
+	Entity    *string           `query:"entity" validate:"omitempty,oneof=service subscription"`
+	EntityId  *int              `query:"id" validate:"omitempty,gte=1"`
+	Actor     *string           `query:"actor" validate:"omitempty,min=1"`
+	Operation *string           `query:"operation" validate:"omitempty,oneof=insert update delete"`
+	From      *types.CustomDate `query:"from" validate:"omitempty"`
+	To        *types.CustomDate `query:"to" validate:"omitempty"`
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/types"
+	"subscription/internal/pkg/validator"
+	svcDto "subscription/internal/service/dto"
+
+	playground "github.com/go-playground/validator"
+)
+
+var exchangeRateColumns = []string{"base_currency", "quote_currency", "effective_date", "rate"}
+
+// ImportExchangeRates loads exchange rates from CSV with the header
+// base_currency,quote_currency,effective_date,rate. Rates of an existing
+// currency pair and date are replaced.
+func (s *service) ImportExchangeRates(ctx context.Context, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return 0, &servererrors.ValidationError{Err: fmt.Errorf("line 1: failed to read header: %w", err)}
+	}
+	columns, err := csvColumns(header, exchangeRateColumns)
+	if err != nil {
+		return 0, &servererrors.ValidationError{Err: fmt.Errorf("line 1: %w", err)}
+	}
+
+	rates := []*model.ExchangeRate{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, &servererrors.ValidationError{Err: err}
+		}
+		line, _ := reader.FieldPos(0)
+		rate, err := parseExchangeRate(record, columns)
+		if err != nil {
+			return 0, &servererrors.ValidationError{Err: fmt.Errorf("line %d: %s", line, describeError(err))}
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	if err := s.repo.ImportExchangeRates(queryCtx, rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+func parseExchangeRate(record []string, columns map[string]int) (*model.ExchangeRate, error) {
+	req := &svcDto.ImportExchangeRate{
+		BaseCurrency:  record[columns["base_currency"]],
+		QuoteCurrency: record[columns["quote_currency"]],
+	}
+	date, err := types.ParseCustomDate(record[columns["effective_date"]])
+	if err != nil {
+		return nil, fmt.Errorf("effective_date: %w", err)
+	}
+	req.EffectiveDate = &date
+	rate, err := strconv.ParseFloat(record[columns["rate"]], 64)
+	if err != nil {
+		return nil, fmt.Errorf("rate: %q is not a number", record[columns["rate"]])
+	}
+	req.Rate = &rate
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+	return &model.ExchangeRate{
+		BaseCurrency:  req.BaseCurrency,
+		QuoteCurrency: req.QuoteCurrency,
+		EffectiveDate: *req.EffectiveDate,
+		Rate:          *req.Rate,
+	}, nil
+}
+
+// csvColumns maps the required columns to their positions in the header.
+func csvColumns(header []string, required []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// describeError renders validation errors as "field: rule" pairs instead of
+// the verbose validator messages.
+func describeError(err error) string {
+	var validationErrs playground.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err.Error()
+	}
+	parts := make([]string, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		part := fmt.Sprintf("%s failed on the '%s' rule", fe.Field(), fe.Tag())
+		if fe.Param() != "" {
+			part = fmt.Sprintf("%s failed on the '%s=%s' rule", fe.Field(), fe.Tag(), fe.Param())
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
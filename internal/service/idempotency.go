@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"subscription/internal/model"
+	"time"
+)
+
+// ClaimIdempotencyKey reserves the key for the request with the fingerprint
+// for the lease and returns nil, or returns the response of the request made
+// with the key before.
+func (s *service) ClaimIdempotencyKey(ctx context.Context, key string, fingerprint string, ttl time.Duration, lease time.Duration) (*model.IdempotentResponse, error) {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.ClaimIdempotencyKey(queryCtx, key, fingerprint, ttl, lease)
+}
+
+// CompleteIdempotencyKey stores the response to replay for the key.
+func (s *service) CompleteIdempotencyKey(ctx context.Context, key string, resp *model.IdempotentResponse) error {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.CompleteIdempotencyKey(queryCtx, key, resp)
+}
+
+// ReleaseIdempotencyKey frees the key of a request that failed so that it
+// can be retried.
+func (s *service) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RemoveIdempotencyKey(queryCtx, key)
+}
+
+// RemoveExpiredIdempotencyKeys deletes the keys whose responses are no
+// longer replayed.
+func (s *service) RemoveExpiredIdempotencyKeys(ctx context.Context) (int, error) {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RemoveExpiredIdempotencyKeys(queryCtx)
+}
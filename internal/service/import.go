@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/types"
+	"subscription/internal/pkg/validator"
+	repoDto "subscription/internal/repository/dto"
+	svcDto "subscription/internal/service/dto"
+
+	"github.com/google/uuid"
+)
+
+// importMaxLines bounds the data lines of an imported file.
+const importMaxLines = 10000
+
+var subscriptionColumns = []string{"service_name", "price", "user_id", "start_date"}
+
+// ImportSubscriptions adds the subscriptions of a CSV file with the header
+// service_name,price,user_id,start_date and the optional columns stop_date,
+// currency and billing_period. Invalid lines are reported and skipped, the
+// others are added in one transaction unless it is a dry run.
+func (s *service) ImportSubscriptions(ctx context.Context, req *svcDto.ImportSubscriptions, r io.Reader) (*model.ImportReport, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, &servererrors.ValidationError{Err: fmt.Errorf("line 1: failed to read header: %w", err)}
+	}
+	columns, err := csvColumns(header, subscriptionColumns)
+	if err != nil {
+		return nil, &servererrors.ValidationError{Err: fmt.Errorf("line 1: %w", err)}
+	}
+
+	report := &model.ImportReport{
+		DryRun:          req.DryRun != nil && *req.DryRun,
+		CreatedServices: []string{},
+		Lines:           []*model.ImportLine{},
+	}
+	ops := []*repoDto.SubscriptionOperation{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &servererrors.ValidationError{Err: err}
+		}
+		if len(report.Lines) == importMaxLines {
+			return nil, &servererrors.ValidationError{Err: fmt.Errorf("the file has more than %d lines", importMaxLines)}
+		}
+		line, _ := reader.FieldPos(0)
+		importLine := &model.ImportLine{Line: line, Status: model.ImportLineValid}
+		subscription, err := parseSubscription(record, columns)
+		if err != nil {
+			importLine.Status = model.ImportLineInvalid
+			importLine.Error = describeError(err)
+		} else {
+			ops = append(ops, &repoDto.SubscriptionOperation{
+				Index:  len(report.Lines),
+				Create: addSubscriptionDto(subscription),
+			})
+		}
+		report.Lines = append(report.Lines, importLine)
+	}
+	report.Total = len(report.Lines)
+
+	if len(ops) > 0 {
+		queryCtx, cancel := s.queryContext(ctx)
+		defer cancel()
+		results, created, err := s.repo.ImportSubscriptions(queryCtx, &repoDto.ImportSubscriptions{
+			Operations:     ops,
+			CreateServices: req.CreateServices != nil && *req.CreateServices,
+			DryRun:         report.DryRun,
+		})
+		if err != nil {
+			return nil, err
+		}
+		report.CreatedServices = created
+		for _, result := range results {
+			importLine := report.Lines[result.Index]
+			switch {
+			case result.Err != nil:
+				importLine.Status = model.ImportLineInvalid
+				importLine.Error = result.Err.Error()
+			case !report.DryRun:
+				importLine.Status = model.ImportLineImported
+				importLine.SubscriptionId = result.Subscription.SubscriptionId
+			}
+		}
+	}
+	for _, importLine := range report.Lines {
+		if importLine.Status == model.ImportLineInvalid {
+			report.Invalid++
+		}
+	}
+	report.Valid = report.Total - report.Invalid
+	return report, nil
+}
+
+// parseSubscription reads a line of an imported file into the request of a
+// single subscription and checks it with the same rules.
+func parseSubscription(record []string, columns map[string]int) (*svcDto.AddSubscription, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	optional := func(name string) *string {
+		if value := field(name); value != "" {
+			return &value
+		}
+		return nil
+	}
+	req := &svcDto.AddSubscription{
+		ServiceName:   optional("service_name"),
+		Currency:      optional("currency"),
+		BillingPeriod: optional("billing_period"),
+	}
+	if value := field("price"); value != "" {
+		price, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("price: %q is not an integer", value)
+		}
+		req.Price = &price
+	}
+	if value := field("user_id"); value != "" {
+		userId, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("user_id: %q is not a UUID", value)
+		}
+		req.UserId = &userId
+	}
+	date := func(name string) (*types.CustomDate, error) {
+		value := field(name)
+		if value == "" {
+			return nil, nil
+		}
+		d, err := types.ParseCustomDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &d, nil
+	}
+	var err error
+	if req.StartDate, err = date("start_date"); err != nil {
+		return nil, err
+	}
+	if req.StopDate, err = date("stop_date"); err != nil {
+		return nil, err
+	}
+	if err := validator.Validate(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
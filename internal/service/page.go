@@ -0,0 +1,146 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/pkg/cursor"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/types"
+	repoDto "subscription/internal/repository/dto"
+)
+
+const (
+	defaultOffset = 0
+	defaultLimit  = 10
+	maxLimit      = 100
+)
+
+type page struct {
+	sort   []repoDto.SortField
+	keyset *repoDto.Keyset
+	offset int
+	limit  int
+}
+
+// newPage resolves the pagination parameters of a list request, the limit
+// is at most maxLimit. The sort always ends with the unique key so that
+// every row has a distinct position; a cursor takes precedence over the
+// offset.
+func newPage(sort *string, key string, cursorStr *string, offset *int, limit *int) (*page, error) {
+	p := &page{
+		offset: defaultOffset,
+		limit:  defaultLimit,
+	}
+	if sort != nil {
+		p.sort = parseSort(*sort)
+	}
+	if len(p.sort) == 0 || p.sort[len(p.sort)-1].Field != key {
+		p.sort = append(p.sort, repoDto.SortField{Field: key})
+	}
+	if offset != nil {
+		p.offset = *offset
+	}
+	if limit != nil {
+		p.limit = min(*limit, maxLimit)
+	}
+	if cursorStr != nil {
+		c, err := cursor.Decode(*cursorStr)
+		if err != nil || c.Sort != formatSort(p.sort) {
+			return nil, servererrors.ErrorInvalidCursor
+		}
+		p.keyset = &repoDto.Keyset{
+			Values:   c.Values,
+			Backward: c.Backward,
+		}
+		p.offset = 0
+	}
+	return p, nil
+}
+
+// paginate drops the extra row requested to detect a further page and
+// returns the cursors of the pages after and before the remaining rows.
+func paginate[T any](p *page, items []T, key func(T) []string) ([]T, string, string) {
+	backward := p.keyset != nil && p.keyset.Backward
+	more := len(items) > p.limit
+	if more {
+		if backward {
+			items = items[len(items)-p.limit:]
+		} else {
+			items = items[:p.limit]
+		}
+	}
+	hasNext, hasPrev := more, p.keyset != nil || p.offset > 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+	if len(items) == 0 {
+		return items, "", ""
+	}
+	var next, prev string
+	sort := formatSort(p.sort)
+	if hasNext {
+		next = cursor.Encode(&cursor.Cursor{Sort: sort, Values: key(items[len(items)-1])})
+	}
+	if hasPrev {
+		prev = cursor.Encode(&cursor.Cursor{Sort: sort, Values: key(items[0]), Backward: true})
+	}
+	return items, next, prev
+}
+
+func parseSort(sort string) []repoDto.SortField {
+	fields := []repoDto.SortField{}
+	for _, field := range strings.Split(sort, ",") {
+		desc := strings.HasPrefix(field, "-")
+		fields = append(fields, repoDto.SortField{
+			Field: strings.TrimPrefix(field, "-"),
+			Desc:  desc,
+		})
+	}
+	return fields
+}
+
+func formatSort(sort []repoDto.SortField) string {
+	fields := make([]string, len(sort))
+	for i, field := range sort {
+		fields[i] = field.Field
+		if field.Desc {
+			fields[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func serviceKey(service *model.Service) []string {
+	return []string{strconv.Itoa(service.ServiceId)}
+}
+
+func auditKey(entry *model.AuditEntry) []string {
+	return []string{strconv.FormatInt(entry.AuditId, 10)}
+}
+
+func subscriptionKey(sort []repoDto.SortField) func(*model.Subscription) []string {
+	return func(subscription *model.Subscription) []string {
+		values := make([]string, len(sort))
+		for i, field := range sort {
+			switch field.Field {
+			case "subscription_id":
+				values[i] = strconv.Itoa(subscription.SubscriptionId)
+			case "service_id":
+				values[i] = strconv.Itoa(subscription.ServiceId)
+			case "price":
+				values[i] = strconv.Itoa(subscription.Price)
+			case "user_id":
+				values[i] = subscription.UserId.String()
+			case "start_date":
+				values[i] = subscription.StartDate.Format(types.DbFormat)
+			case "stop_date":
+				values[i] = "infinity"
+				if subscription.StopDate != nil {
+					values[i] = subscription.StopDate.Format(types.DbFormat)
+				}
+			}
+		}
+		return values
+	}
+}
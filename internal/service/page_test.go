@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"subscription/internal/pkg/cursor"
+	"subscription/internal/pkg/servererrors"
+	repoDto "subscription/internal/repository/dto"
+	"testing"
+)
+
+func TestNewPage(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		sort    *string
+		cursor  *string
+		offset  *int
+		limit   *int
+		want    *page
+		wantErr error
+	}{
+		{
+			name: "defaults",
+			want: &page{sort: []repoDto.SortField{{Field: "id"}}, limit: 10},
+		},
+		{
+			name:   "sort ending with another field",
+			sort:   str("-price,name"),
+			offset: num(20),
+			limit:  num(5),
+			want: &page{
+				sort:   []repoDto.SortField{{Field: "price", Desc: true}, {Field: "name"}, {Field: "id"}},
+				offset: 20,
+				limit:  5,
+			},
+		},
+		{
+			name:  "limit above maximum",
+			limit: num(1000),
+			want:  &page{sort: []repoDto.SortField{{Field: "id"}}, limit: maxLimit},
+		},
+		{
+			name: "sort ending with the key",
+			sort: str("price,-id"),
+			want: &page{sort: []repoDto.SortField{{Field: "price"}, {Field: "id", Desc: true}}, limit: 10},
+		},
+		{
+			name:   "cursor over offset",
+			sort:   str("-price"),
+			cursor: str(cursor.Encode(&cursor.Cursor{Sort: "-price,id", Values: []string{"400", "7"}, Backward: true})),
+			offset: num(20),
+			want: &page{
+				sort:   []repoDto.SortField{{Field: "price", Desc: true}, {Field: "id"}},
+				keyset: &repoDto.Keyset{Values: []string{"400", "7"}, Backward: true},
+				limit:  10,
+			},
+		},
+		{
+			name:    "cursor of another sort",
+			sort:    str("price"),
+			cursor:  str(cursor.Encode(&cursor.Cursor{Sort: "-price,id", Values: []string{"400", "7"}})),
+			wantErr: servererrors.ErrorInvalidCursor,
+		},
+		{
+			name:    "invalid cursor",
+			cursor:  str("not a cursor"),
+			wantErr: servererrors.ErrorInvalidCursor,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPage(tt.sort, "id", tt.cursor, tt.offset, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	sort := []repoDto.SortField{{Field: "id"}}
+	key := func(id int) []string { return []string{strconv.Itoa(id)} }
+	next := func(id int) string {
+		return cursor.Encode(&cursor.Cursor{Sort: "id", Values: key(id)})
+	}
+	prev := func(id int) string {
+		return cursor.Encode(&cursor.Cursor{Sort: "id", Values: key(id), Backward: true})
+	}
+	tests := []struct {
+		name     string
+		page     *page
+		items    []int
+		want     []int
+		wantNext string
+		wantPrev string
+	}{
+		{
+			name:     "first page with more rows",
+			page:     &page{sort: sort, limit: 2},
+			items:    []int{1, 2, 3},
+			want:     []int{1, 2},
+			wantNext: next(2),
+		},
+		{
+			name:  "only page",
+			page:  &page{sort: sort, limit: 2},
+			items: []int{1, 2},
+			want:  []int{1, 2},
+		},
+		{
+			name:     "last page by offset",
+			page:     &page{sort: sort, offset: 2, limit: 2},
+			items:    []int{3},
+			want:     []int{3},
+			wantPrev: prev(3),
+		},
+		{
+			name:     "page after cursor",
+			page:     &page{sort: sort, keyset: &repoDto.Keyset{Values: key(2)}, limit: 2},
+			items:    []int{3, 4, 5},
+			want:     []int{3, 4},
+			wantNext: next(4),
+			wantPrev: prev(3),
+		},
+		{
+			name:     "page before cursor with more rows",
+			page:     &page{sort: sort, keyset: &repoDto.Keyset{Values: key(5), Backward: true}, limit: 2},
+			items:    []int{2, 3, 4},
+			want:     []int{3, 4},
+			wantNext: next(4),
+			wantPrev: prev(3),
+		},
+		{
+			name:     "first page before cursor",
+			page:     &page{sort: sort, keyset: &repoDto.Keyset{Values: key(3), Backward: true}, limit: 2},
+			items:    []int{1, 2},
+			want:     []int{1, 2},
+			wantNext: next(2),
+		},
+		{
+			name:  "empty page after cursor",
+			page:  &page{sort: sort, keyset: &repoDto.Keyset{Values: key(5)}, limit: 2},
+			items: []int{},
+			want:  []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotNext, gotPrev := paginate(tt.page, tt.items, key)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got items %v, want %v", got, tt.want)
+			}
+			if gotNext != tt.wantNext {
+				t.Fatalf("got next cursor %q, want %q", gotNext, tt.wantNext)
+			}
+			if gotPrev != tt.wantPrev {
+				t.Fatalf("got previous cursor %q, want %q", gotPrev, tt.wantPrev)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"subscription/internal/pkg/mergepatch"
+	"subscription/internal/pkg/servererrors"
+	repoDto "subscription/internal/repository/dto"
+	svcDto "subscription/internal/service/dto"
+)
+
+// patchAttempts bounds the attempts of a patch without a version given when
+// the record keeps changing between reading and updating it.
+const patchAttempts = 3
+
+func (s *service) PatchService(ctx context.Context, req *svcDto.PatchService) error {
+	if err := validate(req); err != nil {
+		return err
+	}
+	return retryPatch(req.Versions, func() error {
+		return s.patchService(ctx, req)
+	})
+}
+func (s *service) patchService(ctx context.Context, req *svcDto.PatchService) error {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	current, err := s.repo.GetService(queryCtx, &repoDto.GetService{ServiceId: *req.ServiceId})
+	if err != nil {
+		return err
+	}
+	if req.Versions != nil && !slices.Contains(req.Versions, current.Version) {
+		return servererrors.ErrorVersionMismatch
+	}
+	update := new(svcDto.UpdateService)
+	doc := map[string]any{
+		"name": current.Name,
+	}
+	if err := applyPatch(doc, req.Patch, update); err != nil {
+		return err
+	}
+	update.ServiceId = req.ServiceId
+	update.Versions = []int64{current.Version}
+	return s.UpdateService(ctx, update)
+}
+
+func (s *service) PatchSubscription(ctx context.Context, req *svcDto.PatchSubscription) error {
+	if err := validate(req); err != nil {
+		return err
+	}
+	return retryPatch(req.Versions, func() error {
+		return s.patchSubscription(ctx, req)
+	})
+}
+func (s *service) patchSubscription(ctx context.Context, req *svcDto.PatchSubscription) error {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	current, err := s.repo.GetSubscription(queryCtx, &repoDto.GetSubscription{SubscriptionId: *req.SubscriptionId})
+	if err != nil {
+		return err
+	}
+	if req.Versions != nil && !slices.Contains(req.Versions, current.Version) {
+		return servererrors.ErrorVersionMismatch
+	}
+	service, err := s.repo.GetService(queryCtx, &repoDto.GetService{ServiceId: current.ServiceId, IncludeDeleted: true})
+	if err != nil {
+		return err
+	}
+	update := new(svcDto.UpdateSubscription)
+	doc := map[string]any{
+		"service_name":   service.Name,
+		"price":          current.Price,
+		"user_id":        current.UserId,
+		"start_date":     current.StartDate,
+		"stop_date":      current.StopDate,
+		"billing_period": current.BillingPeriod,
+		"currency":       current.Currency,
+	}
+	if err := applyPatch(doc, req.Patch, update); err != nil {
+		return err
+	}
+	update.SubscriptionId = req.SubscriptionId
+	update.Versions = []int64{current.Version}
+	return s.UpdateSubscription(ctx, update)
+}
+
+// applyPatch merges the patch into the document of the current record and
+// decodes the result into the update request, which is validated as a whole
+// afterwards.
+func applyPatch(doc map[string]any, patch []byte, update any) error {
+	current, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	merged, err := mergepatch.Apply(current, patch)
+	if err != nil {
+		return &servererrors.ValidationError{Err: err}
+	}
+	return decodeRecord(merged, update)
+}
+
+// decodeRecord decodes a JSON object into req rejecting unknown fields, the
+// errors are reported as validation errors.
+func decodeRecord(data []byte, req any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			err = fmt.Errorf("%s has an invalid type", typeErr.Field)
+			if typeErr.Field == "" {
+				err = errors.New("the record must be a JSON object")
+			}
+		}
+		return &servererrors.ValidationError{Err: err}
+	}
+	return nil
+}
+
+// retryPatch repeats a patch made without the versions expected by the client
+// when the record changed after it was read.
+func retryPatch(versions []int64, patch func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := patch()
+		if versions == nil && attempt < patchAttempts && errors.Is(err, servererrors.ErrorVersionMismatch) {
+			continue
+		}
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"subscription/internal/pkg/servererrors"
+	svcDto "subscription/internal/service/dto"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	doc := map[string]any{
+		"service_name": "Netflix",
+		"price":        400,
+		"start_date":   "2025-01-01",
+		"stop_date":    "2025-12-01",
+	}
+	tests := []struct {
+		name     string
+		patch    string
+		wantErr  string
+		validate func(update *svcDto.UpdateSubscription) bool
+	}{
+		{
+			name:  "replace price",
+			patch: `{"price":500}`,
+			validate: func(update *svcDto.UpdateSubscription) bool {
+				return *update.Price == 500 && *update.ServiceName == "Netflix" && update.StopDate != nil
+			},
+		},
+		{
+			name:  "remove stop date",
+			patch: `{"stop_date":null}`,
+			validate: func(update *svcDto.UpdateSubscription) bool {
+				return update.StopDate == nil && *update.Price == 400
+			},
+		},
+		{
+			name:    "unknown field",
+			patch:   `{"owner":"someone"}`,
+			wantErr: `json: unknown field "owner"`,
+		},
+		{
+			name:    "invalid type",
+			patch:   `{"price":"free"}`,
+			wantErr: "price has an invalid type",
+		},
+		{
+			name:    "patch replacing the record",
+			patch:   `[1]`,
+			wantErr: "the record must be a JSON object",
+		},
+		{
+			name:    "invalid patch",
+			patch:   `{"price":`,
+			wantErr: "invalid merge patch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := new(svcDto.UpdateSubscription)
+			err := applyPatch(doc, []byte(tt.patch), update)
+			if tt.wantErr != "" {
+				var validationErr *servererrors.ValidationError
+				if !errors.As(err, &validationErr) || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want validation error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !tt.validate(update) {
+				t.Fatalf("got %+v", update)
+			}
+		})
+	}
+}
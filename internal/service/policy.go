@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"subscription/internal/model"
+	"subscription/internal/pkg/auth"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	svcDto "subscription/internal/service/dto"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// policy restricts the callers of a Service. Admins manage the service
+// catalog and every subscription, the other users only the subscriptions
+// whose user_id is their subject. Callers with an API key are limited to the
+// scopes of the key and are denied the actions of admins without a scope.
+// Exchange rates are shared by the tenants and are imported only by users
+// with the platform role. Requests without a principal, made with
+// authentication disabled or by background jobs, are not restricted.
+type policy struct {
+	Service
+	lg           *zap.SugaredLogger
+	adminRole    string
+	platformRole string
+}
+
+func NewPolicy(svc Service, lg *zap.SugaredLogger, adminRole string, platformRole string) Service {
+	return &policy{
+		Service:      svc,
+		lg:           lg,
+		adminRole:    adminRole,
+		platformRole: platformRole,
+	}
+}
+
+func (p *policy) deny(principal *auth.Principal, action string) error {
+	if principal.APIKey {
+		p.lg.Warnf("access denied: %s by %q with scopes %v", action, principal.Subject, principal.Scopes)
+	} else {
+		p.lg.Warnf("access denied: %s by %q with roles %v", action, principal.Subject, principal.Roles)
+	}
+	return servererrors.ErrorForbidden
+}
+
+// unrestricted reports whether the caller may act on every record of the
+// tenant with the scope; it denies the action to an API key without the scope
+// and to a caller of another tenant than the one of ctx.
+func (p *policy) unrestricted(ctx context.Context, principal *auth.Principal, scope string, action string) (bool, error) {
+	switch {
+	case principal == nil:
+		return true, nil
+	case principal.Tenant != tenant.FromContext(ctx):
+		return false, p.deny(principal, action)
+	case principal.APIKey:
+		if scope == "" || !slices.Contains(principal.Scopes, scope) {
+			return false, p.deny(principal, action)
+		}
+		return true, nil
+	}
+	return slices.Contains(principal.Roles, p.adminRole), nil
+}
+
+// requireAdmin denies the action to the users without the admin role and to
+// the API keys without the scope.
+func (p *policy) requireAdmin(ctx context.Context, scope string, action string) error {
+	principal := auth.FromContext(ctx)
+	ok, err := p.unrestricted(ctx, principal, scope, action)
+	if err != nil || ok {
+		return err
+	}
+	return p.deny(principal, action)
+}
+
+// requirePlatform denies the action to the users without the platform role
+// and to every API key, the keys belong to a tenant.
+func (p *policy) requirePlatform(ctx context.Context, action string) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil || !principal.APIKey && slices.Contains(principal.Roles, p.platformRole) {
+		return nil
+	}
+	return p.deny(principal, action)
+}
+
+// requireScope denies the action to the API keys without the scope only.
+func (p *policy) requireScope(ctx context.Context, scope string, action string) error {
+	_, err := p.unrestricted(ctx, auth.FromContext(ctx), scope, action)
+	return err
+}
+
+// owner returns the user the subscriptions of the caller are limited to, nil
+// for an unrestricted caller. A subject that is not a user ID owns nothing.
+func (p *policy) owner(ctx context.Context, scope string, action string) (*uuid.UUID, error) {
+	principal := auth.FromContext(ctx)
+	ok, err := p.unrestricted(ctx, principal, scope, action)
+	if err != nil || ok {
+		return nil, err
+	}
+	userId, err := uuid.Parse(principal.Subject)
+	if err != nil {
+		return nil, p.deny(principal, action)
+	}
+	return &userId, nil
+}
+
+// checkUser denies the action when a user other than the owner is given.
+func (p *policy) checkUser(ctx context.Context, owner *uuid.UUID, userId *uuid.UUID, action string) error {
+	if owner == nil || userId == nil || *userId == *owner {
+		return nil
+	}
+	return p.deny(auth.FromContext(ctx), action)
+}
+
+// checkSubscription denies the action on a subscription of another user.
+func (p *policy) checkSubscription(ctx context.Context, subscriptionId *int, scope string, action string) error {
+	owner, err := p.owner(ctx, scope, action)
+	if err != nil || owner == nil || subscriptionId == nil {
+		return err
+	}
+	includeDeleted := true
+	subscription, err := p.Service.GetSubscription(ctx, &svcDto.GetSubscription{
+		SubscriptionId: subscriptionId,
+		IncludeDeleted: &includeDeleted,
+	})
+	if err != nil {
+		return err
+	}
+	return p.checkUser(ctx, owner, &subscription.UserId, action)
+}
+
+func (p *policy) GetService(ctx context.Context, req *svcDto.GetService) (*model.Service, error) {
+	if err := p.requireScope(ctx, auth.ScopeReadServices, "get service"); err != nil {
+		return nil, err
+	}
+	return p.Service.GetService(ctx, req)
+}
+func (p *policy) GetServices(ctx context.Context, req *svcDto.GetServices) (*model.ServiceList, error) {
+	if err := p.requireScope(ctx, auth.ScopeReadServices, "get services"); err != nil {
+		return nil, err
+	}
+	return p.Service.GetServices(ctx, req)
+}
+func (p *policy) AddService(ctx context.Context, req *svcDto.AddService) (*model.Service, error) {
+	if err := p.requireAdmin(ctx, auth.ScopeWriteServices, "add service"); err != nil {
+		return nil, err
+	}
+	return p.Service.AddService(ctx, req)
+}
+func (p *policy) UpdateService(ctx context.Context, req *svcDto.UpdateService) error {
+	if err := p.requireAdmin(ctx, auth.ScopeWriteServices, "update service"); err != nil {
+		return err
+	}
+	return p.Service.UpdateService(ctx, req)
+}
+func (p *policy) PatchService(ctx context.Context, req *svcDto.PatchService) error {
+	if err := p.requireAdmin(ctx, auth.ScopeWriteServices, "patch service"); err != nil {
+		return err
+	}
+	return p.Service.PatchService(ctx, req)
+}
+func (p *policy) RemoveService(ctx context.Context, req *svcDto.RemoveService) error {
+	if err := p.requireAdmin(ctx, auth.ScopeWriteServices, "remove service"); err != nil {
+		return err
+	}
+	return p.Service.RemoveService(ctx, req)
+}
+func (p *policy) RestoreService(ctx context.Context, req *svcDto.RestoreService) error {
+	if err := p.requireAdmin(ctx, auth.ScopeWriteServices, "restore service"); err != nil {
+		return err
+	}
+	return p.Service.RestoreService(ctx, req)
+}
+
+func (p *policy) AddSubscription(ctx context.Context, req *svcDto.AddSubscription) (*model.Subscription, error) {
+	owner, err := p.owner(ctx, auth.ScopeWriteSubscriptions, "add subscription")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkUser(ctx, owner, req.UserId, "add subscription of another user"); err != nil {
+		return nil, err
+	}
+	return p.Service.AddSubscription(ctx, req)
+}
+func (p *policy) GetSubscription(ctx context.Context, req *svcDto.GetSubscription) (*model.Subscription, error) {
+	owner, err := p.owner(ctx, auth.ScopeReadSubscriptions, "get subscription")
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := p.Service.GetSubscription(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkUser(ctx, owner, &subscription.UserId, "get subscription of another user"); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+func (p *policy) GetSubscriptions(ctx context.Context, req *svcDto.GetSubscriptions) (*model.SubscriptionList, error) {
+	owner, err := p.owner(ctx, auth.ScopeReadSubscriptions, "get subscriptions")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkUser(ctx, owner, req.UserId, "get subscriptions of another user"); err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		req.UserId = owner
+	}
+	return p.Service.GetSubscriptions(ctx, req)
+}
+func (p *policy) ExportSubscriptions(ctx context.Context, req *svcDto.ExportSubscriptions) (model.SubscriptionCursor, error) {
+	owner, err := p.owner(ctx, auth.ScopeReadSubscriptions, "export subscriptions")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkUser(ctx, owner, req.UserId, "export subscriptions of another user"); err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		req.UserId = owner
+	}
+	return p.Service.ExportSubscriptions(ctx, req)
+}
+func (p *policy) GetSubscriptionTotal(ctx context.Context, req *svcDto.GetSubscriptionTotal) (*model.SubscriptionTotal, error) {
+	owner, err := p.owner(ctx, auth.ScopeReports, "get subscription total")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkUser(ctx, owner, req.UserId, "get subscription total of another user"); err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		req.UserId = owner
+	}
+	return p.Service.GetSubscriptionTotal(ctx, req)
+}
+func (p *policy) GetSubscriptionTotalRevision(ctx context.Context, req *svcDto.GetSubscriptionTotalQuery) (*model.DataRevision, error) {
+	owner, err := p.owner(ctx, auth.ScopeReports, "get subscription total")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkUser(ctx, owner, req.UserId, "get subscription total of another user"); err != nil {
+		return nil, err
+	}
+	return p.Service.GetSubscriptionTotalRevision(ctx, req)
+}
+func (p *policy) UpdateSubscription(ctx context.Context, req *svcDto.UpdateSubscription) error {
+	action := "update subscription of another user"
+	owner, err := p.owner(ctx, auth.ScopeWriteSubscriptions, action)
+	if err != nil {
+		return err
+	}
+	if err := p.checkUser(ctx, owner, req.UserId, action); err != nil {
+		return err
+	}
+	if err := p.checkSubscription(ctx, req.SubscriptionId, auth.ScopeWriteSubscriptions, action); err != nil {
+		return err
+	}
+	return p.Service.UpdateSubscription(ctx, req)
+}
+func (p *policy) PatchSubscription(ctx context.Context, req *svcDto.PatchSubscription) error {
+	action := "patch subscription of another user"
+	owner, err := p.owner(ctx, auth.ScopeWriteSubscriptions, action)
+	if err != nil {
+		return err
+	}
+	// the patch must not hand the subscription over to another user
+	var patch struct {
+		UserId *uuid.UUID `json:"user_id"`
+	}
+	if owner != nil && json.Unmarshal(req.Patch, &patch) == nil {
+		if err := p.checkUser(ctx, owner, patch.UserId, action); err != nil {
+			return err
+		}
+	}
+	if err := p.checkSubscription(ctx, req.SubscriptionId, auth.ScopeWriteSubscriptions, action); err != nil {
+		return err
+	}
+	return p.Service.PatchSubscription(ctx, req)
+}
+func (p *policy) GetSubscriptionPrices(ctx context.Context, req *svcDto.GetSubscriptionPrices) ([]*model.SubscriptionPrice, error) {
+	if err := p.checkSubscription(ctx, req.SubscriptionId, auth.ScopeReadSubscriptions, "get prices of subscription of another user"); err != nil {
+		return nil, err
+	}
+	return p.Service.GetSubscriptionPrices(ctx, req)
+}
+func (p *policy) RemoveSubscription(ctx context.Context, req *svcDto.RemoveSubscription) error {
+	if err := p.checkSubscription(ctx, req.SubscriptionId, auth.ScopeWriteSubscriptions, "remove subscription of another user"); err != nil {
+		return err
+	}
+	return p.Service.RemoveSubscription(ctx, req)
+}
+func (p *policy) RestoreSubscription(ctx context.Context, req *svcDto.RestoreSubscription) error {
+	if err := p.checkSubscription(ctx, req.SubscriptionId, auth.ScopeWriteSubscriptions, "restore subscription of another user"); err != nil {
+		return err
+	}
+	return p.Service.RestoreSubscription(ctx, req)
+}
+
+// BatchSubscriptions rejects the whole batch when one of its operations is
+// denied.
+func (p *policy) BatchSubscriptions(ctx context.Context, req *svcDto.BatchSubscriptions) ([]*model.BatchResult, error) {
+	action := "batch operation on subscription of another user"
+	owner, err := p.owner(ctx, auth.ScopeWriteSubscriptions, action)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range req.Operations {
+		// a missing operation is reported by the validation of the batch
+		if owner == nil || op == nil {
+			continue
+		}
+		var subscription struct {
+			UserId *uuid.UUID `json:"user_id"`
+		}
+		if json.Unmarshal(op.Subscription, &subscription) == nil {
+			if err := p.checkUser(ctx, owner, subscription.UserId, action); err != nil {
+				return nil, &servererrors.BatchError{Index: i, Err: err}
+			}
+		}
+		if op.Op == nil || *op.Op == model.BatchOperationCreate {
+			continue
+		}
+		// a missing subscription is reported by the batch itself
+		if err := p.checkSubscription(ctx, op.SubscriptionId, auth.ScopeWriteSubscriptions, action); errors.Is(err, servererrors.ErrorForbidden) {
+			return nil, &servererrors.BatchError{Index: i, Err: err}
+		}
+	}
+	return p.Service.BatchSubscriptions(ctx, req)
+}
+func (p *policy) ImportSubscriptions(ctx context.Context, req *svcDto.ImportSubscriptions, r io.Reader) (*model.ImportReport, error) {
+	if err := p.requireAdmin(ctx, auth.ScopeWriteSubscriptions, "import subscriptions"); err != nil {
+		return nil, err
+	}
+	return p.Service.ImportSubscriptions(ctx, req, r)
+}
+func (p *policy) PurgeDeleted(ctx context.Context, retention time.Duration) (int, int, error) {
+	if err := p.requireAdmin(ctx, "", "purge deleted records"); err != nil {
+		return 0, 0, err
+	}
+	return p.Service.PurgeDeleted(ctx, retention)
+}
+func (p *policy) ImportExchangeRates(ctx context.Context, r io.Reader) (int, error) {
+	if err := p.requirePlatform(ctx, "import exchange rates"); err != nil {
+		return 0, err
+	}
+	return p.Service.ImportExchangeRates(ctx, r)
+}
+func (p *policy) GetAuditLog(ctx context.Context, req *svcDto.GetAuditLog) (*model.AuditList, error) {
+	if err := p.requireAdmin(ctx, "", "get audit log"); err != nil {
+		return nil, err
+	}
+	return p.Service.GetAuditLog(ctx, req)
+}
+func (p *policy) AddAPIKey(ctx context.Context, req *svcDto.AddAPIKey) (*model.NewAPIKey, error) {
+	if err := p.requireAdmin(ctx, "", "add api key"); err != nil {
+		return nil, err
+	}
+	return p.Service.AddAPIKey(ctx, req)
+}
+func (p *policy) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	if err := p.requireAdmin(ctx, "", "get api keys"); err != nil {
+		return nil, err
+	}
+	return p.Service.GetAPIKeys(ctx)
+}
+func (p *policy) RotateAPIKey(ctx context.Context, req *svcDto.RotateAPIKey) (*model.NewAPIKey, error) {
+	if err := p.requireAdmin(ctx, "", "rotate api key"); err != nil {
+		return nil, err
+	}
+	return p.Service.RotateAPIKey(ctx, req)
+}
+func (p *policy) RevokeAPIKey(ctx context.Context, req *svcDto.RevokeAPIKey) error {
+	if err := p.requireAdmin(ctx, "", "revoke api key"); err != nil {
+		return err
+	}
+	return p.Service.RevokeAPIKey(ctx, req)
+}
@@ -0,0 +1,488 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"subscription/internal/model"
+	"subscription/internal/pkg/auth"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/tenant"
+	svcDto "subscription/internal/service/dto"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ownerId = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	otherId = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+)
+
+// fakeService answers the calls the policy lets through, GetSubscription
+// returns subscription 1 of the owner and 2 of the other user.
+type fakeService struct {
+	Service
+	called  bool
+	request any
+}
+
+func (s *fakeService) AddService(ctx context.Context, req *svcDto.AddService) (*model.Service, error) {
+	s.called = true
+	return &model.Service{}, nil
+}
+func (s *fakeService) GetServices(ctx context.Context, req *svcDto.GetServices) (*model.ServiceList, error) {
+	s.called = true
+	return &model.ServiceList{}, nil
+}
+func (s *fakeService) AddSubscription(ctx context.Context, req *svcDto.AddSubscription) (*model.Subscription, error) {
+	s.called = true
+	return &model.Subscription{}, nil
+}
+func (s *fakeService) GetSubscription(ctx context.Context, req *svcDto.GetSubscription) (*model.Subscription, error) {
+	switch *req.SubscriptionId {
+	case 1:
+		return &model.Subscription{SubscriptionId: 1, UserId: ownerId}, nil
+	case 2:
+		return &model.Subscription{SubscriptionId: 2, UserId: otherId}, nil
+	}
+	return nil, servererrors.ErrorRecordNotFound
+}
+func (s *fakeService) GetSubscriptions(ctx context.Context, req *svcDto.GetSubscriptions) (*model.SubscriptionList, error) {
+	s.called, s.request = true, req
+	return &model.SubscriptionList{}, nil
+}
+func (s *fakeService) UpdateSubscription(ctx context.Context, req *svcDto.UpdateSubscription) error {
+	s.called = true
+	return nil
+}
+func (s *fakeService) PatchSubscription(ctx context.Context, req *svcDto.PatchSubscription) error {
+	s.called = true
+	return nil
+}
+func (s *fakeService) RemoveSubscription(ctx context.Context, req *svcDto.RemoveSubscription) error {
+	s.called = true
+	return nil
+}
+func (s *fakeService) BatchSubscriptions(ctx context.Context, req *svcDto.BatchSubscriptions) ([]*model.BatchResult, error) {
+	s.called = true
+	return nil, nil
+}
+func (s *fakeService) ImportSubscriptions(ctx context.Context, req *svcDto.ImportSubscriptions, r io.Reader) (*model.ImportReport, error) {
+	s.called = true
+	return &model.ImportReport{}, nil
+}
+func (s *fakeService) ImportExchangeRates(ctx context.Context, r io.Reader) (int, error) {
+	s.called = true
+	return 0, nil
+}
+func (s *fakeService) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	s.called = true
+	return nil, nil
+}
+
+func TestPolicy(t *testing.T) {
+	admin := &auth.Principal{Subject: "admin", Tenant: "acme", Roles: []string{"admin"}}
+	user := &auth.Principal{Subject: ownerId.String(), Tenant: "acme", Roles: []string{"user"}}
+	service := &auth.Principal{Subject: "billing", Tenant: "acme"}
+	platform := &auth.Principal{Subject: "operator", Tenant: "acme", Roles: []string{"platform-admin"}}
+	foreignAdmin := &auth.Principal{Subject: "admin", Tenant: "globex", Roles: []string{"admin"}}
+	key := func(scopes ...string) *auth.Principal {
+		return &auth.Principal{Subject: "key", Tenant: "acme", APIKey: true, Scopes: scopes}
+	}
+	id := func(id int) *int { return &id }
+	op := func(op string) *string { return &op }
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		call      func(ctx context.Context, svc Service) error
+		wantErr   bool
+	}{
+		{
+			name: "no principal adds service",
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddService(ctx, &svcDto.AddService{})
+				return err
+			},
+		},
+		{
+			name:      "admin adds service",
+			principal: admin,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddService(ctx, &svcDto.AddService{})
+				return err
+			},
+		},
+		{
+			name:      "user adds service",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddService(ctx, &svcDto.AddService{})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "admin of another tenant adds service",
+			principal: foreignAdmin,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddService(ctx, &svcDto.AddService{})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "key with scope adds service",
+			principal: key(auth.ScopeWriteServices),
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddService(ctx, &svcDto.AddService{})
+				return err
+			},
+		},
+		{
+			name:      "key without scope adds service",
+			principal: key(auth.ScopeReadServices),
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddService(ctx, &svcDto.AddService{})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user gets services",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.GetServices(ctx, &svcDto.GetServices{})
+				return err
+			},
+		},
+		{
+			name:      "key without scope gets services",
+			principal: key(auth.ScopeWriteSubscriptions),
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.GetServices(ctx, &svcDto.GetServices{})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user adds own subscription",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddSubscription(ctx, &svcDto.AddSubscription{UserId: &ownerId})
+				return err
+			},
+		},
+		{
+			name:      "user adds subscription of another user",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddSubscription(ctx, &svcDto.AddSubscription{UserId: &otherId})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "admin adds subscription of another user",
+			principal: admin,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddSubscription(ctx, &svcDto.AddSubscription{UserId: &otherId})
+				return err
+			},
+		},
+		{
+			name:      "subject that is not a user adds subscription",
+			principal: service,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.AddSubscription(ctx, &svcDto.AddSubscription{UserId: &ownerId})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user gets subscriptions of another user",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.GetSubscriptions(ctx, &svcDto.GetSubscriptions{UserId: &otherId})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user updates own subscription",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.UpdateSubscription(ctx, &svcDto.UpdateSubscription{SubscriptionId: id(1), UserId: &ownerId})
+			},
+		},
+		{
+			name:      "user hands own subscription over with update",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.UpdateSubscription(ctx, &svcDto.UpdateSubscription{SubscriptionId: id(1), UserId: &otherId})
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user takes subscription of another user with update",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.UpdateSubscription(ctx, &svcDto.UpdateSubscription{SubscriptionId: id(2), UserId: &ownerId})
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user patches own subscription",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.PatchSubscription(ctx, &svcDto.PatchSubscription{SubscriptionId: id(1), Patch: []byte(`{"price":100}`)})
+			},
+		},
+		{
+			name:      "user hands own subscription over with patch",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.PatchSubscription(ctx, &svcDto.PatchSubscription{SubscriptionId: id(1), Patch: []byte(`{"user_id":"` + otherId.String() + `"}`)})
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user patches subscription of another user",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.PatchSubscription(ctx, &svcDto.PatchSubscription{SubscriptionId: id(2), Patch: []byte(`{"user_id":"` + ownerId.String() + `"}`)})
+			},
+			wantErr: true,
+		},
+		{
+			name:      "admin hands subscription over with patch",
+			principal: admin,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.PatchSubscription(ctx, &svcDto.PatchSubscription{SubscriptionId: id(1), Patch: []byte(`{"user_id":"` + otherId.String() + `"}`)})
+			},
+		},
+		{
+			name:      "user removes subscription of another user",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				return svc.RemoveSubscription(ctx, &svcDto.RemoveSubscription{SubscriptionId: id(2)})
+			},
+			wantErr: true,
+		},
+		{
+			name:      "key with scope removes subscription of any user",
+			principal: key(auth.ScopeWriteSubscriptions),
+			call: func(ctx context.Context, svc Service) error {
+				return svc.RemoveSubscription(ctx, &svcDto.RemoveSubscription{SubscriptionId: id(2)})
+			},
+		},
+		{
+			name:      "user batches own subscriptions",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.BatchSubscriptions(ctx, &svcDto.BatchSubscriptions{Operations: []*svcDto.BatchSubscriptionOperation{
+					{Op: op(model.BatchOperationCreate), Subscription: []byte(`{"user_id":"` + ownerId.String() + `"}`)},
+					{Op: op(model.BatchOperationUpdate), SubscriptionId: id(1), Subscription: []byte(`{"user_id":"` + ownerId.String() + `"}`)},
+					{Op: op(model.BatchOperationDelete), SubscriptionId: id(3)},
+				}})
+				return err
+			},
+		},
+		{
+			name:      "user creates subscription of another user in batch",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.BatchSubscriptions(ctx, &svcDto.BatchSubscriptions{Operations: []*svcDto.BatchSubscriptionOperation{
+					{Op: op(model.BatchOperationDelete), SubscriptionId: id(1)},
+					{Op: op(model.BatchOperationCreate), Subscription: []byte(`{"user_id":"` + otherId.String() + `"}`)},
+				}})
+				return wantBatchError(err, 1)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user creates subscription of another user after missing operation in batch",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.BatchSubscriptions(ctx, &svcDto.BatchSubscriptions{Operations: []*svcDto.BatchSubscriptionOperation{
+					nil,
+					{Op: op(model.BatchOperationCreate), Subscription: []byte(`{"user_id":"` + otherId.String() + `"}`)},
+				}})
+				return wantBatchError(err, 1)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user hands own subscription over in batch",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.BatchSubscriptions(ctx, &svcDto.BatchSubscriptions{Operations: []*svcDto.BatchSubscriptionOperation{
+					{Op: op(model.BatchOperationUpdate), SubscriptionId: id(1), Subscription: []byte(`{"user_id":"` + otherId.String() + `"}`)},
+				}})
+				return wantBatchError(err, 0)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "user removes subscription of another user in batch",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.BatchSubscriptions(ctx, &svcDto.BatchSubscriptions{Operations: []*svcDto.BatchSubscriptionOperation{
+					{Op: op(model.BatchOperationCreate), Subscription: []byte(`{"user_id":"` + ownerId.String() + `"}`)},
+					{Op: op(model.BatchOperationDelete), SubscriptionId: id(2)},
+				}})
+				return wantBatchError(err, 1)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "admin batches subscriptions of other users",
+			principal: admin,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.BatchSubscriptions(ctx, &svcDto.BatchSubscriptions{Operations: []*svcDto.BatchSubscriptionOperation{
+					{Op: op(model.BatchOperationUpdate), SubscriptionId: id(1), Subscription: []byte(`{"user_id":"` + otherId.String() + `"}`)},
+					{Op: op(model.BatchOperationDelete), SubscriptionId: id(2)},
+				}})
+				return err
+			},
+		},
+		{
+			name:      "user imports subscriptions",
+			principal: user,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.ImportSubscriptions(ctx, &svcDto.ImportSubscriptions{}, strings.NewReader(""))
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "key with scope imports subscriptions",
+			principal: key(auth.ScopeWriteSubscriptions),
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.ImportSubscriptions(ctx, &svcDto.ImportSubscriptions{}, strings.NewReader(""))
+				return err
+			},
+		},
+		{
+			name:      "platform user imports exchange rates",
+			principal: platform,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.ImportExchangeRates(ctx, strings.NewReader(""))
+				return err
+			},
+		},
+		{
+			name:      "admin imports exchange rates",
+			principal: admin,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.ImportExchangeRates(ctx, strings.NewReader(""))
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "key imports exchange rates",
+			principal: key(auth.ScopeWriteServices, auth.ScopeWriteSubscriptions),
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.ImportExchangeRates(ctx, strings.NewReader(""))
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:      "admin gets api keys",
+			principal: admin,
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.GetAPIKeys(ctx)
+				return err
+			},
+		},
+		{
+			name: "key with every scope gets api keys",
+			principal: key(auth.ScopeReadServices, auth.ScopeWriteServices, auth.ScopeReadSubscriptions,
+				auth.ScopeWriteSubscriptions, auth.ScopeReports),
+			call: func(ctx context.Context, svc Service) error {
+				_, err := svc.GetAPIKeys(ctx)
+				return err
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeService{}
+			svc := NewPolicy(fake, zap.NewNop().Sugar(), "admin", "platform-admin")
+			ctx := tenant.NewContext(context.Background(), "acme")
+			if tt.principal != nil {
+				ctx = auth.NewContext(ctx, tt.principal)
+			}
+			err := tt.call(ctx, svc)
+			switch {
+			case tt.wantErr && !errors.Is(err, servererrors.ErrorForbidden):
+				t.Fatalf("got error %v, want %v", err, servererrors.ErrorForbidden)
+			case !tt.wantErr && err != nil:
+				t.Fatalf("got error %v", err)
+			case fake.called == tt.wantErr:
+				t.Fatalf("service called: %v", fake.called)
+			}
+		})
+	}
+}
+
+// wantBatchError returns err when it is the error of the operation at index.
+func wantBatchError(err error, index int) error {
+	var batchErr *servererrors.BatchError
+	if err != nil && (!errors.As(err, &batchErr) || batchErr.Index != index) {
+		return fmt.Errorf("not the error of operation %d: %v", index, err)
+	}
+	return err
+}
+
+func TestPolicyLimitsSubscriptionsToOwner(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		userId    *uuid.UUID
+		want      *uuid.UUID
+	}{
+		{
+			name:      "user without filter",
+			principal: &auth.Principal{Subject: ownerId.String(), Tenant: "acme"},
+			want:      &ownerId,
+		},
+		{
+			name:      "user with own filter",
+			principal: &auth.Principal{Subject: ownerId.String(), Tenant: "acme"},
+			userId:    &ownerId,
+			want:      &ownerId,
+		},
+		{
+			name:      "admin without filter",
+			principal: &auth.Principal{Subject: "admin", Tenant: "acme", Roles: []string{"admin"}},
+		},
+		{
+			name:      "admin with filter",
+			principal: &auth.Principal{Subject: "admin", Tenant: "acme", Roles: []string{"admin"}},
+			userId:    &otherId,
+			want:      &otherId,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeService{}
+			svc := NewPolicy(fake, zap.NewNop().Sugar(), "admin", "platform-admin")
+			ctx := auth.NewContext(tenant.NewContext(context.Background(), "acme"), tt.principal)
+			if _, err := svc.GetSubscriptions(ctx, &svcDto.GetSubscriptions{UserId: tt.userId}); err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			got := fake.request.(*svcDto.GetSubscriptions).UserId
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Fatalf("got user %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
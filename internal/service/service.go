@@ -1,240 +1,431 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"subscription/internal/model"
+	"subscription/internal/pkg/actor"
+	"subscription/internal/pkg/auth"
 	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/types"
 	"subscription/internal/pkg/validator"
 	"subscription/internal/repository"
 	repoDto "subscription/internal/repository/dto"
 	svcDto "subscription/internal/service/dto"
+	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
 
 type Service interface {
-	AddService(ctx *fiber.Ctx) error
-	GetService(ctx *fiber.Ctx) error
-	GetServices(ctx *fiber.Ctx) error
-	UpdateService(ctx *fiber.Ctx) error
-	RemoveService(ctx *fiber.Ctx) error
+	Health(ctx context.Context) *model.Health
 
-	AddSubscription(ctx *fiber.Ctx) error
-	GetSubscription(ctx *fiber.Ctx) error
-	GetSubscriptions(ctx *fiber.Ctx) error
-	GetSubscriptionTotal(ctx *fiber.Ctx) error
-	UpdateSubscription(ctx *fiber.Ctx) error
-	RemoveSubscription(ctx *fiber.Ctx) error
+	AddService(ctx context.Context, req *svcDto.AddService) (*model.Service, error)
+	GetService(ctx context.Context, req *svcDto.GetService) (*model.Service, error)
+	GetServices(ctx context.Context, req *svcDto.GetServices) (*model.ServiceList, error)
+	UpdateService(ctx context.Context, req *svcDto.UpdateService) error
+	PatchService(ctx context.Context, req *svcDto.PatchService) error
+	RemoveService(ctx context.Context, req *svcDto.RemoveService) error
+	RestoreService(ctx context.Context, req *svcDto.RestoreService) error
+
+	AddSubscription(ctx context.Context, req *svcDto.AddSubscription) (*model.Subscription, error)
+	GetSubscription(ctx context.Context, req *svcDto.GetSubscription) (*model.Subscription, error)
+	GetSubscriptions(ctx context.Context, req *svcDto.GetSubscriptions) (*model.SubscriptionList, error)
+	ExportSubscriptions(ctx context.Context, req *svcDto.ExportSubscriptions) (model.SubscriptionCursor, error)
+	GetSubscriptionTotal(ctx context.Context, req *svcDto.GetSubscriptionTotal) (*model.SubscriptionTotal, error)
+	UpdateSubscription(ctx context.Context, req *svcDto.UpdateSubscription) error
+	PatchSubscription(ctx context.Context, req *svcDto.PatchSubscription) error
+	GetSubscriptionPrices(ctx context.Context, req *svcDto.GetSubscriptionPrices) ([]*model.SubscriptionPrice, error)
+	RemoveSubscription(ctx context.Context, req *svcDto.RemoveSubscription) error
+	RestoreSubscription(ctx context.Context, req *svcDto.RestoreSubscription) error
+	BatchSubscriptions(ctx context.Context, req *svcDto.BatchSubscriptions) ([]*model.BatchResult, error)
+	ImportSubscriptions(ctx context.Context, req *svcDto.ImportSubscriptions, r io.Reader) (*model.ImportReport, error)
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int, int, error)
+
+	ImportExchangeRates(ctx context.Context, r io.Reader) (int, error)
+
+	GetSubscriptionTotalRevision(ctx context.Context, req *svcDto.GetSubscriptionTotalQuery) (*model.DataRevision, error)
+
+	GetAuditLog(ctx context.Context, req *svcDto.GetAuditLog) (*model.AuditList, error)
+
+	AddAPIKey(ctx context.Context, req *svcDto.AddAPIKey) (*model.NewAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]*model.APIKey, error)
+	RotateAPIKey(ctx context.Context, req *svcDto.RotateAPIKey) (*model.NewAPIKey, error)
+	RevokeAPIKey(ctx context.Context, req *svcDto.RevokeAPIKey) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error)
+
+	ClaimIdempotencyKey(ctx context.Context, key string, fingerprint string, ttl time.Duration, lease time.Duration) (*model.IdempotentResponse, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, resp *model.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	RemoveExpiredIdempotencyKeys(ctx context.Context) (int, error)
 }
 
+// purgeActor is recorded in the audit log for the records purged.
+const purgeActor = "purge"
+
+// maxTotalYears bounds the period of a total, the months of the period are
+// joined to the prices and the exchange rates.
+const maxTotalYears = 10
+
 type service struct {
-	repo repository.Repository
-	lg   *zap.SugaredLogger
+	repo         repository.Repository
+	lg           *zap.SugaredLogger
+	queryTimeout time.Duration
 }
 
-func New(repo repository.Repository, lg *zap.SugaredLogger) *service {
+func New(repo repository.Repository, lg *zap.SugaredLogger, queryTimeout time.Duration) *service {
 	return &service{
-		repo: repo,
-		lg:   lg,
+		repo:         repo,
+		lg:           lg,
+		queryTimeout: queryTimeout,
 	}
 
 }
 
-func (s *service) AddService(ctx *fiber.Ctx) error {
-	req := new(svcDto.AddService)
-	if err := ctx.BodyParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	resp, err := s.repo.AddService(*req.Name)
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
-	}
-	return ctx.Status(200).JSON(resp)
+func (s *service) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.queryTimeout)
 }
-func (s *service) GetService(ctx *fiber.Ctx) error {
-	req := new(svcDto.GetService)
-	if err := ctx.ParamsParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
+
+func validate(req any) error {
 	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+		return &servererrors.ValidationError{Err: err}
 	}
-	resp, err := s.repo.GetService(*req.ServiceId)
-	if err == servererrors.ErrorRecordNotFound {
-		return ctx.SendStatus(404)
+	return nil
+}
+
+func (s *service) Health(ctx context.Context) *model.Health {
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	status := model.HealthStatusOk
+	if err := s.repo.Ping(queryCtx); err != nil {
+		status = model.HealthStatusUnavailable
 	}
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+	return &model.Health{
+		Status: status,
+		Pool:   s.repo.GetPoolStats(),
 	}
-	return ctx.Status(200).JSON(resp)
 }
-func (s *service) GetServices(ctx *fiber.Ctx) error {
-	resp, err := s.repo.GetServices()
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+
+func (s *service) AddService(ctx context.Context, req *svcDto.AddService) (*model.Service, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
-	return ctx.Status(200).JSON(resp)
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.AddService(queryCtx, *req.Name)
 }
-func (s *service) UpdateService(ctx *fiber.Ctx) error {
-	req := new(svcDto.UpdateService)
-	if err := ctx.BodyParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	if err := ctx.ParamsParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	err := s.repo.UpdateService(&repoDto.UpdateService{
-		ServiceId: *req.ServiceId,
-		Name:      *req.Name,
+func (s *service) GetService(ctx context.Context, req *svcDto.GetService) (*model.Service, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.GetService(queryCtx, &repoDto.GetService{
+		ServiceId:      *req.ServiceId,
+		IncludeDeleted: req.IncludeDeleted != nil && *req.IncludeDeleted,
 	})
-	if err == servererrors.ErrorRecordNotFound {
-		return ctx.SendStatus(404)
-	}
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
-	}
-	return ctx.SendStatus(204)
 }
-func (s *service) RemoveService(ctx *fiber.Ctx) error {
-	req := new(svcDto.RemoveService)
-	if err := ctx.ParamsParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	err := s.repo.RemoveService(*req.ServiceId)
-	if err == servererrors.ErrorRecordNotFound {
-		return ctx.SendStatus(404)
+func (s *service) GetServices(ctx context.Context, req *svcDto.GetServices) (*model.ServiceList, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
+	p, err := newPage(nil, "service_id", req.Cursor, req.Offset, req.Limit)
 	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+		return nil, err
 	}
-	return ctx.SendStatus(204)
-}
-
-func (s *service) AddSubscription(ctx *fiber.Ctx) error {
 
-	req := new(svcDto.AddSubscription)
-
-	if err := ctx.BodyParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	resp, err := s.repo.AddSubscription(&repoDto.AddSubscription{
-		ServiceName: *req.ServiceName,
-		Price:       *req.Price,
-		UserId:      *req.UserId,
-		StartDate:   *req.StartDate,
-		StopDate:    req.StopDate,
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	services, total, err := s.repo.GetServices(queryCtx, &repoDto.GetServices{
+		IncludeDeleted: req.IncludeDeleted != nil && *req.IncludeDeleted,
+		Sort:           p.sort,
+		Keyset:         p.keyset,
+		Offset:         p.offset,
+		Limit:          p.limit + 1,
 	})
 	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
-	}
-	return ctx.Status(201).JSON(resp)
+		return nil, err
+	}
+	services, next, prev := paginate(p, services, serviceKey)
+	return &model.ServiceList{
+		Items:      services,
+		Total:      total,
+		Offset:     p.offset,
+		Limit:      p.limit,
+		NextCursor: next,
+		PrevCursor: prev,
+	}, nil
 }
-func (s *service) GetSubscription(ctx *fiber.Ctx) error {
-	req := new(svcDto.GetSubscription)
-	if err := ctx.ParamsParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+func (s *service) UpdateService(ctx context.Context, req *svcDto.UpdateService) error {
+	if err := validate(req); err != nil {
+		return err
 	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.UpdateService(queryCtx, &repoDto.UpdateService{
+		ServiceId: *req.ServiceId,
+		Name:      *req.Name,
+		Versions:  req.Versions,
+	})
+}
+func (s *service) RemoveService(ctx context.Context, req *svcDto.RemoveService) error {
+	if err := validate(req); err != nil {
+		return err
 	}
-	resp, err := s.repo.GetSubscription(*req.SubscriptionId)
-	if err == servererrors.ErrorRecordNotFound {
-		return ctx.SendStatus(404)
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RemoveService(queryCtx, &repoDto.RemoveService{
+		ServiceId: *req.ServiceId,
+		Versions:  req.Versions,
+	})
+}
+func (s *service) RestoreService(ctx context.Context, req *svcDto.RestoreService) error {
+	if err := validate(req); err != nil {
+		return err
 	}
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RestoreService(queryCtx, *req.ServiceId)
+}
+
+func (s *service) AddSubscription(ctx context.Context, req *svcDto.AddSubscription) (*model.Subscription, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
-	return ctx.Status(200).JSON(resp)
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.AddSubscription(queryCtx, addSubscriptionDto(req))
 }
-func (s *service) GetSubscriptions(ctx *fiber.Ctx) error {
-	req := new(svcDto.GetSubscriptions)
-	if err := ctx.QueryParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+func addSubscriptionDto(req *svcDto.AddSubscription) *repoDto.AddSubscription {
+	billingPeriod := model.BillingPeriodMonthly
+	if req.BillingPeriod != nil {
+		billingPeriod = *req.BillingPeriod
+	}
+	currency := model.DefaultCurrency
+	if req.Currency != nil {
+		currency = *req.Currency
+	}
+	return &repoDto.AddSubscription{
+		ServiceName:   *req.ServiceName,
+		Price:         *req.Price,
+		Currency:      currency,
+		UserId:        *req.UserId,
+		StartDate:     *req.StartDate,
+		StopDate:      req.StopDate,
+		BillingPeriod: billingPeriod,
 	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+}
+func (s *service) GetSubscription(ctx context.Context, req *svcDto.GetSubscription) (*model.Subscription, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
-	resp, err := s.repo.GetSubscriptions(&repoDto.GetSubscriptions{
-		Offset: req.Offset,
-		Limit:  req.Limit,
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.GetSubscription(queryCtx, &repoDto.GetSubscription{
+		SubscriptionId: *req.SubscriptionId,
+		IncludeDeleted: req.IncludeDeleted != nil && *req.IncludeDeleted,
 	})
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
-	}
-	return ctx.Status(200).JSON(resp)
 }
-func (s *service) GetSubscriptionTotal(ctx *fiber.Ctx) error {
-	req := new(svcDto.GetSubscriptionTotal)
-	if err := ctx.BodyParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
-	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+func (s *service) GetSubscriptions(ctx context.Context, req *svcDto.GetSubscriptions) (*model.SubscriptionList, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
-	resp, err := s.repo.GetSubscriptionTotal(&repoDto.GetSubscriptionTotal{
-		StartDate:   *req.StartDate,
-		StopDate:    *req.StopDate,
-		UserId:      req.UserId,
-		ServiceName: req.ServiceName,
-	})
+	p, err := newPage(req.Sort, "subscription_id", req.Cursor, req.Offset, req.Limit)
 	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+		return nil, err
+	}
+	dto := &repoDto.GetSubscriptions{
+		Filter: repoDto.SubscriptionFilter{
+			UserId:         req.UserId,
+			ServiceId:      req.ServiceId,
+			ServiceName:    req.ServiceName,
+			Search:         req.Search,
+			PriceMin:       req.PriceMin,
+			PriceMax:       req.PriceMax,
+			ActiveOn:       req.ActiveOn,
+			StartFrom:      req.StartFrom,
+			StartTo:        req.StartTo,
+			StopFrom:       req.StopFrom,
+			StopTo:         req.StopTo,
+			OpenEnded:      req.OpenEnded != nil && *req.OpenEnded,
+			IncludeDeleted: req.IncludeDeleted != nil && *req.IncludeDeleted,
+		},
+		Sort:   p.sort,
+		Keyset: p.keyset,
+		Offset: p.offset,
+		Limit:  p.limit + 1,
 	}
-	return ctx.Status(200).JSON(fiber.Map{"total": resp})
+
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	subscriptions, total, err := s.repo.GetSubscriptions(queryCtx, dto)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, next, prev := paginate(p, subscriptions, subscriptionKey(p.sort))
+	return &model.SubscriptionList{
+		Items:      subscriptions,
+		Total:      total,
+		Offset:     p.offset,
+		Limit:      p.limit,
+		NextCursor: next,
+		PrevCursor: prev,
+	}, nil
 }
-func (s *service) UpdateSubscription(ctx *fiber.Ctx) error {
-	req := new(svcDto.UpdateSubscription)
 
-	if err := ctx.BodyParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+// ExportSubscriptions validates req and starts the export. The query is not
+// bounded by the query timeout as it lasts as long as the rows are read, ctx
+// has to be canceled when the export is done or abandoned.
+func (s *service) ExportSubscriptions(ctx context.Context, req *svcDto.ExportSubscriptions) (model.SubscriptionCursor, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	p, err := newPage(req.Sort, "subscription_id", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ExportSubscriptions(ctx, &repoDto.ExportSubscriptions{
+		Filter: repoDto.SubscriptionFilter{
+			UserId:         req.UserId,
+			ServiceId:      req.ServiceId,
+			ServiceName:    req.ServiceName,
+			Search:         req.Search,
+			PriceMin:       req.PriceMin,
+			PriceMax:       req.PriceMax,
+			ActiveOn:       req.ActiveOn,
+			StartFrom:      req.StartFrom,
+			StartTo:        req.StartTo,
+			StopFrom:       req.StopFrom,
+			StopTo:         req.StopTo,
+			OpenEnded:      req.OpenEnded != nil && *req.OpenEnded,
+			IncludeDeleted: req.IncludeDeleted != nil && *req.IncludeDeleted,
+		},
+		Sort: p.sort,
+	})
+}
+func (s *service) GetSubscriptionTotal(ctx context.Context, req *svcDto.GetSubscriptionTotal) (*model.SubscriptionTotal, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
-	if err := ctx.ParamsParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+	if err := checkTotalPeriod(*req.StartDate, *req.StopDate, "stop_date"); err != nil {
+		return nil, err
 	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+	currency := model.DefaultCurrency
+	if req.Currency != nil {
+		currency = *req.Currency
 	}
-	err := s.repo.UpdateSubscription(&repoDto.UpdateSubscription{
-		SubscriptionId: *req.SubscriptionId,
-		ServiceName:    *req.ServiceName,
-		Price:          *req.Price,
-		UserId:         *req.UserId,
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	breakdown, err := s.repo.GetSubscriptionTotal(queryCtx, &repoDto.GetSubscriptionTotal{
 		StartDate:      *req.StartDate,
-		StopDate:       req.StopDate,
+		StopDate:       *req.StopDate,
+		UserId:         req.UserId,
+		ServiceName:    req.ServiceName,
+		GroupByService: slices.Contains(req.GroupBy, "service"),
+		GroupByUser:    slices.Contains(req.GroupBy, "user"),
+		Prorate:        req.Prorate != nil && *req.Prorate,
+		Currency:       currency,
+		IncludeDeleted: req.IncludeDeleted != nil && *req.IncludeDeleted,
 	})
-	if err == servererrors.ErrorRecordNotFound {
-		return ctx.SendStatus(404)
-	}
 	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+		return nil, err
+	}
+	total := 0
+	for _, row := range breakdown {
+		total += row.Amount
 	}
-	return ctx.SendStatus(204)
+	return &model.SubscriptionTotal{
+		Total:     total,
+		Currency:  currency,
+		Breakdown: breakdown,
+	}, nil
 }
-func (s *service) RemoveSubscription(ctx *fiber.Ctx) error {
-	req := new(svcDto.RemoveSubscription)
-	if err := ctx.ParamsParser(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+func (s *service) UpdateSubscription(ctx context.Context, req *svcDto.UpdateSubscription) error {
+	if err := validate(req); err != nil {
+		return err
 	}
-	if err := validator.Validate(req); err != nil {
-		return ctx.Status(400).SendString(err.Error())
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.UpdateSubscription(queryCtx, updateSubscriptionDto(req))
+}
+func updateSubscriptionDto(req *svcDto.UpdateSubscription) *repoDto.UpdateSubscription {
+	return &repoDto.UpdateSubscription{
+		SubscriptionId:     *req.SubscriptionId,
+		ServiceName:        *req.ServiceName,
+		Price:              *req.Price,
+		PriceEffectiveDate: req.PriceEffectiveDate,
+		UserId:             *req.UserId,
+		StartDate:          *req.StartDate,
+		StopDate:           req.StopDate,
+		BillingPeriod:      req.BillingPeriod,
+		Currency:           req.Currency,
+		Versions:           req.Versions,
 	}
-	err := s.repo.RemoveSubscription(*req.SubscriptionId)
-	if err == servererrors.ErrorRecordNotFound {
-		return ctx.SendStatus(404)
+}
+func (s *service) GetSubscriptionPrices(ctx context.Context, req *svcDto.GetSubscriptionPrices) ([]*model.SubscriptionPrice, error) {
+	if err := validate(req); err != nil {
+		return nil, err
 	}
-	if err != nil {
-		return ctx.Status(500).SendString(err.Error())
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.GetSubscriptionPrices(queryCtx, *req.SubscriptionId)
+}
+func (s *service) RemoveSubscription(ctx context.Context, req *svcDto.RemoveSubscription) error {
+	if err := validate(req); err != nil {
+		return err
 	}
-	return ctx.SendStatus(204)
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RemoveSubscription(queryCtx, &repoDto.RemoveSubscription{
+		SubscriptionId: *req.SubscriptionId,
+		Versions:       req.Versions,
+	})
+}
+func (s *service) RestoreSubscription(ctx context.Context, req *svcDto.RestoreSubscription) error {
+	if err := validate(req); err != nil {
+		return err
+	}
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.RestoreSubscription(queryCtx, *req.SubscriptionId)
+}
+
+// PurgeDeleted removes the records deleted more than retention ago for good
+// and returns the number of subscriptions and services removed.
+func (s *service) PurgeDeleted(ctx context.Context, retention time.Duration) (int, int, error) {
+	queryCtx, cancel := s.queryContext(actor.NewContext(ctx, purgeActor))
+	defer cancel()
+	return s.repo.PurgeDeleted(queryCtx, time.Now().Add(-retention))
+}
+
+// GetSubscriptionTotalRevision validates req and returns the revision of the
+// data the total is computed from, a total is the same as long as it is.
+func (s *service) GetSubscriptionTotalRevision(ctx context.Context, req *svcDto.GetSubscriptionTotalQuery) (*model.DataRevision, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	if err := checkTotalPeriod(*req.From, *req.To, "to"); err != nil {
+		return nil, err
+	}
+	queryCtx, cancel := s.queryContext(ctx)
+	defer cancel()
+	return s.repo.GetDataRevision(queryCtx)
+}
+
+// checkTotalPeriod rejects a period of a total that ends before it starts or
+// spans more than maxTotalYears, the error is reported for the field ending
+// the period.
+func checkTotalPeriod(start types.CustomDate, stop types.CustomDate, stopField string) error {
+	switch {
+	case stop.Before(start.Time):
+		return &servererrors.PeriodError{Field: stopField, Err: servererrors.ErrorInvalidPeriod}
+	case stop.After(start.AddDate(maxTotalYears, 0, 0)):
+		return &servererrors.PeriodError{
+			Field: stopField,
+			Err:   fmt.Errorf("%w: at most %d years", servererrors.ErrorPeriodTooLong, maxTotalYears),
+		}
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscription/internal/pkg/servererrors"
+	"subscription/internal/pkg/types"
+	svcDto "subscription/internal/service/dto"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionTotalPeriod(t *testing.T) {
+	date := func(year int, month time.Month, day int) *types.CustomDate {
+		return &types.CustomDate{Time: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+	}
+	tests := []struct {
+		name      string
+		start     *types.CustomDate
+		stop      *types.CustomDate
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:      "inverted period",
+			start:     date(2025, 3, 1),
+			stop:      date(2025, 2, 28),
+			wantErr:   servererrors.ErrorInvalidPeriod,
+			wantField: "stop_date",
+		},
+		{
+			name:      "period above maximum",
+			start:     date(2015, 1, 1),
+			stop:      date(2025, 1, 2),
+			wantErr:   servererrors.ErrorPeriodTooLong,
+			wantField: "stop_date",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &service{}
+			_, err := svc.GetSubscriptionTotal(context.Background(), &svcDto.GetSubscriptionTotal{
+				StartDate: tt.start,
+				StopDate:  tt.stop,
+			})
+			var periodErr *servererrors.PeriodError
+			if !errors.As(err, &periodErr) || !errors.Is(err, tt.wantErr) || periodErr.Field != tt.wantField {
+				t.Fatalf("got error %v, want %v of %s", err, tt.wantErr, tt.wantField)
+			}
+
+			_, err = svc.GetSubscriptionTotalRevision(context.Background(), &svcDto.GetSubscriptionTotalQuery{
+				From: tt.start,
+				To:   tt.stop,
+			})
+			if !errors.As(err, &periodErr) || !errors.Is(err, tt.wantErr) || periodErr.Field != "to" {
+				t.Fatalf("got error %v, want %v of to", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckTotalPeriod(t *testing.T) {
+	start := types.CustomDate{Time: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)}
+	for _, stop := range []time.Time{start.Time, start.AddDate(0, 0, 1), start.AddDate(maxTotalYears, 0, 0)} {
+		if err := checkTotalPeriod(start, types.CustomDate{Time: stop}, "stop_date"); err != nil {
+			t.Errorf("period to %s: got error %v", stop.Format(types.DbFormat), err)
+		}
+	}
+}
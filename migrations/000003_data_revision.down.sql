@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS subscriptions_data_revision ON public.subscriptions;
+DROP TRIGGER IF EXISTS services_data_revision ON public.services;
+DROP FUNCTION IF EXISTS public.bump_data_revision();
+DROP TABLE IF EXISTS public.data_revision;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS public.data_revision(
+    revision_id integer NOT NULL DEFAULT 1,
+    revision bigint NOT NULL DEFAULT 0,
+    modified_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT data_revision_pk PRIMARY KEY (revision_id),
+    CONSTRAINT data_revision_single_row CHECK (revision_id = 1)
+);
+INSERT INTO public.data_revision DEFAULT VALUES ON CONFLICT DO NOTHING;
+CREATE OR REPLACE FUNCTION public.bump_data_revision() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE public.data_revision SET revision=revision+1, modified_at=now() WHERE revision_id=1;
+    RETURN NULL;
+END;
+$$;
+CREATE TRIGGER services_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.services
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
+CREATE TRIGGER subscriptions_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.subscriptions
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
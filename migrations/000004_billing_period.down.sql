@@ -0,0 +1,3 @@
+ALTER TABLE public.subscriptions DROP CONSTRAINT IF EXISTS billing_periods_fk;
+ALTER TABLE public.subscriptions DROP COLUMN IF EXISTS billing_period;
+DROP TABLE IF EXISTS public.billing_periods;
\ No newline at end of file
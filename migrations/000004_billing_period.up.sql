@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS public.billing_periods(
+    billing_period character varying COLLATE pg_catalog."default" NOT NULL,
+    months integer NOT NULL DEFAULT 0,
+    days integer NOT NULL DEFAULT 0,
+    CONSTRAINT billing_periods_pk PRIMARY KEY (billing_period),
+    CONSTRAINT billing_periods_length CHECK ((months > 0 AND days = 0) OR (months = 0 AND days > 0))
+);
+INSERT INTO public.billing_periods(billing_period,months,days)
+VALUES ('weekly',0,7),('monthly',1,0),('quarterly',3,0),('yearly',12,0)
+ON CONFLICT DO NOTHING;
+ALTER TABLE public.subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period character varying COLLATE pg_catalog."default" NOT NULL DEFAULT 'monthly',
+    ADD CONSTRAINT billing_periods_fk FOREIGN KEY (billing_period)
+        REFERENCES public.billing_periods (billing_period) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE RESTRICT;
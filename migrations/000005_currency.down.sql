@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.exchange_rates;
+ALTER TABLE public.subscriptions DROP CONSTRAINT IF EXISTS subscriptions_currency;
+ALTER TABLE public.subscriptions DROP COLUMN IF EXISTS currency;
+UPDATE public.subscriptions SET price=price/100;
+ALTER TABLE public.subscriptions ALTER COLUMN price TYPE integer;
\ No newline at end of file
@@ -0,0 +1,20 @@
+ALTER TABLE public.subscriptions ALTER COLUMN price TYPE bigint;
+-- prices are kept in minor units of the currency from version 2.0.0 of the API
+UPDATE public.subscriptions SET price=price*100;
+ALTER TABLE public.subscriptions
+    ADD COLUMN IF NOT EXISTS currency character(3) COLLATE pg_catalog."default" NOT NULL DEFAULT 'RUB',
+    ADD CONSTRAINT subscriptions_currency CHECK (currency ~ '^[A-Z]{3}$');
+CREATE TABLE IF NOT EXISTS public.exchange_rates(
+    base_currency character(3) COLLATE pg_catalog."default" NOT NULL,
+    quote_currency character(3) COLLATE pg_catalog."default" NOT NULL,
+    effective_date date NOT NULL,
+    rate numeric(20,10) NOT NULL,
+    CONSTRAINT exchange_rates_pk PRIMARY KEY (base_currency, quote_currency, effective_date),
+    CONSTRAINT exchange_rates_rate CHECK (rate > 0)
+);
+CREATE INDEX IF NOT EXISTS exchange_rates_quote_currency
+    ON public.exchange_rates USING btree
+    (quote_currency, base_currency, effective_date);
+CREATE TRIGGER exchange_rates_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.exchange_rates
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.subscription_prices;
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS public.subscription_prices(
+    subscription_id bigint NOT NULL,
+    effective_date date NOT NULL,
+    price bigint NOT NULL,
+    CONSTRAINT subscription_prices_pk PRIMARY KEY (subscription_id, effective_date),
+    CONSTRAINT subscriptions_fk FOREIGN KEY (subscription_id)
+        REFERENCES public.subscriptions (subscription_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT subscription_prices_price CHECK (price >= 0)
+);
+INSERT INTO public.subscription_prices(subscription_id,effective_date,price)
+SELECT subscription_id,start_date,price FROM public.subscriptions
+ON CONFLICT DO NOTHING;
+CREATE TRIGGER subscription_prices_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.subscription_prices
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
\ No newline at end of file
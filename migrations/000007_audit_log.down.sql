@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS subscriptions_audit_update ON public.subscriptions;
+DROP TRIGGER IF EXISTS subscriptions_audit ON public.subscriptions;
+DROP TRIGGER IF EXISTS services_audit_update ON public.services;
+DROP TRIGGER IF EXISTS services_audit ON public.services;
+DROP TABLE IF EXISTS public.audit_log;
+DROP FUNCTION IF EXISTS public.audit_log_append_only();
+DROP FUNCTION IF EXISTS public.audit_change();
\ No newline at end of file
@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS public.audit_log(
+    audit_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    changed_at timestamp with time zone NOT NULL DEFAULT now(),
+    actor text NOT NULL,
+    operation text NOT NULL,
+    entity text NOT NULL,
+    entity_id bigint NOT NULL,
+    before jsonb,
+    after jsonb,
+    CONSTRAINT audit_log_pk PRIMARY KEY (audit_id),
+    CONSTRAINT audit_log_operation CHECK (operation IN ('insert', 'update', 'delete'))
+);
+CREATE INDEX IF NOT EXISTS audit_log_entity ON public.audit_log (entity, entity_id, audit_id);
+CREATE INDEX IF NOT EXISTS audit_log_changed_at ON public.audit_log (changed_at);
+-- audit_change records a row change; the arguments are the entity name and its key column.
+-- The actor is set by the application for the transaction with set_config('app.actor',...)
+CREATE OR REPLACE FUNCTION public.audit_change() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+DECLARE
+    old_row jsonb := CASE WHEN TG_OP IN ('UPDATE', 'DELETE') THEN to_jsonb(OLD) END;
+    new_row jsonb := CASE WHEN TG_OP IN ('INSERT', 'UPDATE') THEN to_jsonb(NEW) END;
+BEGIN
+    INSERT INTO public.audit_log(actor,operation,entity,entity_id,before,after)
+    VALUES (
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), 'unknown'),
+        lower(TG_OP),
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row)->>TG_ARGV[1])::bigint,
+        old_row,
+        new_row
+    );
+    RETURN NULL;
+END;
+$$;
+CREATE OR REPLACE FUNCTION public.audit_log_append_only() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON public.audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION public.audit_log_append_only();
+CREATE TRIGGER services_audit
+    AFTER INSERT OR DELETE ON public.services
+    FOR EACH ROW EXECUTE FUNCTION public.audit_change('service', 'service_id');
+CREATE TRIGGER services_audit_update
+    AFTER UPDATE ON public.services
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION public.audit_change('service', 'service_id');
+CREATE TRIGGER subscriptions_audit
+    AFTER INSERT OR DELETE ON public.subscriptions
+    FOR EACH ROW EXECUTE FUNCTION public.audit_change('subscription', 'subscription_id');
+CREATE TRIGGER subscriptions_audit_update
+    AFTER UPDATE ON public.subscriptions
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION public.audit_change('subscription', 'subscription_id');
\ No newline at end of file
@@ -0,0 +1,10 @@
+DELETE FROM public.subscriptions WHERE deleted_at IS NOT NULL;
+DELETE FROM public.services WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS public.subscriptions_deleted_at;
+DROP INDEX IF EXISTS public.services_deleted_at;
+DROP INDEX IF EXISTS public.services_name;
+CREATE UNIQUE INDEX IF NOT EXISTS services_name
+    ON public.services USING btree
+    (name COLLATE pg_catalog."default" ASC NULLS LAST);
+ALTER TABLE public.subscriptions DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE public.services DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
@@ -0,0 +1,13 @@
+ALTER TABLE public.services ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+ALTER TABLE public.subscriptions ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+DROP INDEX IF EXISTS public.services_name;
+CREATE UNIQUE INDEX IF NOT EXISTS services_name
+    ON public.services USING btree
+    (name COLLATE pg_catalog."default" ASC NULLS LAST)
+    WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS services_deleted_at
+    ON public.services (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS subscriptions_deleted_at
+    ON public.subscriptions (deleted_at)
+    WHERE deleted_at IS NOT NULL;
\ No newline at end of file
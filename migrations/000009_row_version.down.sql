@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS subscriptions_row_version ON public.subscriptions;
+DROP TRIGGER IF EXISTS services_row_version ON public.services;
+DROP FUNCTION IF EXISTS public.bump_row_version();
+ALTER TABLE public.subscriptions DROP COLUMN IF EXISTS version;
+ALTER TABLE public.services DROP COLUMN IF EXISTS version;
\ No newline at end of file
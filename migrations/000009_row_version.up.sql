@@ -0,0 +1,15 @@
+ALTER TABLE public.services ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE public.subscriptions ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+CREATE OR REPLACE FUNCTION public.bump_row_version() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$;
+CREATE TRIGGER services_row_version
+    BEFORE UPDATE ON public.services
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION public.bump_row_version();
+CREATE TRIGGER subscriptions_row_version
+    BEFORE UPDATE ON public.subscriptions
+    FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION public.bump_row_version();
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS public.idempotency_keys(
+    actor text NOT NULL,
+    idempotency_key character varying(255) NOT NULL,
+    fingerprint character(64) NOT NULL,
+    status integer,
+    content_type text,
+    body bytea,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    expires_at timestamp with time zone NOT NULL,
+    CONSTRAINT idempotency_keys_pk PRIMARY KEY (actor, idempotency_key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at ON public.idempotency_keys (expires_at);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.api_keys;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS public.api_keys(
+    api_key_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    name character varying(255) NOT NULL,
+    prefix character varying(16) NOT NULL,
+    key_hash character(64) NOT NULL,
+    scopes text[] NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    expires_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    CONSTRAINT api_keys_pk PRIMARY KEY (api_key_id),
+    CONSTRAINT api_keys_key_hash UNIQUE (key_hash)
+);
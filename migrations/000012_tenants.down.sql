@@ -0,0 +1,67 @@
+DROP POLICY IF EXISTS audit_log_tenant ON public.audit_log;
+ALTER TABLE public.audit_log NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE public.audit_log DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS subscription_prices_tenant ON public.subscription_prices;
+ALTER TABLE public.subscription_prices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE public.subscription_prices DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS subscriptions_tenant ON public.subscriptions;
+ALTER TABLE public.subscriptions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE public.subscriptions DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS services_tenant ON public.services;
+ALTER TABLE public.services NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE public.services DISABLE ROW LEVEL SECURITY;
+DROP FUNCTION IF EXISTS public.tenant_visible(character varying);
+
+CREATE OR REPLACE FUNCTION public.audit_change() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+DECLARE
+    old_row jsonb := CASE WHEN TG_OP IN ('UPDATE', 'DELETE') THEN to_jsonb(OLD) END;
+    new_row jsonb := CASE WHEN TG_OP IN ('INSERT', 'UPDATE') THEN to_jsonb(NEW) END;
+BEGIN
+    INSERT INTO public.audit_log(actor,operation,entity,entity_id,before,after)
+    VALUES (
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), 'unknown'),
+        lower(TG_OP),
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row)->>TG_ARGV[1])::bigint,
+        old_row,
+        new_row
+    );
+    RETURN NULL;
+END;
+$$;
+
+-- the keys of the other tenants than the default one may collide and are dropped
+DELETE FROM public.idempotency_keys WHERE tenant_id<>'default';
+ALTER TABLE public.idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pk,
+    ADD CONSTRAINT idempotency_keys_pk PRIMARY KEY (actor, idempotency_key);
+DROP INDEX IF EXISTS public.api_keys_tenant;
+DROP INDEX IF EXISTS public.audit_log_tenant;
+ALTER TABLE public.subscription_prices
+    DROP CONSTRAINT IF EXISTS subscriptions_fk,
+    ADD CONSTRAINT subscriptions_fk FOREIGN KEY (subscription_id)
+        REFERENCES public.subscriptions (subscription_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE;
+ALTER TABLE public.subscriptions
+    DROP CONSTRAINT IF EXISTS services_fk,
+    ADD CONSTRAINT services_fk FOREIGN KEY (service_id)
+        REFERENCES public.services (service_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE RESTRICT;
+ALTER TABLE public.subscriptions DROP CONSTRAINT IF EXISTS subscriptions_tenant_subscription_id;
+ALTER TABLE public.services DROP CONSTRAINT IF EXISTS services_tenant_service_id;
+-- fails when services of different tenants share a name
+DROP INDEX IF EXISTS public.services_name;
+CREATE UNIQUE INDEX IF NOT EXISTS services_name
+    ON public.services USING btree
+    (name COLLATE pg_catalog."default" ASC NULLS LAST)
+    WHERE deleted_at IS NULL;
+
+ALTER TABLE public.api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE public.idempotency_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE public.audit_log DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE public.subscription_prices DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE public.subscriptions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE public.services DROP COLUMN IF EXISTS tenant_id;
\ No newline at end of file
@@ -0,0 +1,82 @@
+-- every row belongs to a tenant; the rows made before tenants are given the
+-- default tenant
+ALTER TABLE public.services ADD COLUMN IF NOT EXISTS tenant_id character varying(63) NOT NULL DEFAULT 'default';
+ALTER TABLE public.subscriptions ADD COLUMN IF NOT EXISTS tenant_id character varying(63) NOT NULL DEFAULT 'default';
+ALTER TABLE public.subscription_prices ADD COLUMN IF NOT EXISTS tenant_id character varying(63) NOT NULL DEFAULT 'default';
+ALTER TABLE public.audit_log ADD COLUMN IF NOT EXISTS tenant_id character varying(63) NOT NULL DEFAULT 'default';
+ALTER TABLE public.idempotency_keys ADD COLUMN IF NOT EXISTS tenant_id character varying(63) NOT NULL DEFAULT 'default';
+ALTER TABLE public.api_keys ADD COLUMN IF NOT EXISTS tenant_id character varying(63) NOT NULL DEFAULT 'default';
+ALTER TABLE public.services ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE public.subscriptions ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE public.subscription_prices ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE public.audit_log ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE public.idempotency_keys ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE public.api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS public.services_name;
+CREATE UNIQUE INDEX IF NOT EXISTS services_name
+    ON public.services USING btree
+    (tenant_id, name COLLATE pg_catalog."default" ASC NULLS LAST)
+    WHERE deleted_at IS NULL;
+-- the references include the tenant so that no row points into another tenant
+ALTER TABLE public.services ADD CONSTRAINT services_tenant_service_id UNIQUE (tenant_id, service_id);
+ALTER TABLE public.subscriptions ADD CONSTRAINT subscriptions_tenant_subscription_id UNIQUE (tenant_id, subscription_id);
+ALTER TABLE public.subscriptions
+    DROP CONSTRAINT IF EXISTS services_fk,
+    ADD CONSTRAINT services_fk FOREIGN KEY (tenant_id, service_id)
+        REFERENCES public.services (tenant_id, service_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE RESTRICT;
+ALTER TABLE public.subscription_prices
+    DROP CONSTRAINT IF EXISTS subscriptions_fk,
+    ADD CONSTRAINT subscriptions_fk FOREIGN KEY (tenant_id, subscription_id)
+        REFERENCES public.subscriptions (tenant_id, subscription_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS audit_log_tenant ON public.audit_log (tenant_id, audit_id);
+ALTER TABLE public.idempotency_keys
+    DROP CONSTRAINT IF EXISTS idempotency_keys_pk,
+    ADD CONSTRAINT idempotency_keys_pk PRIMARY KEY (tenant_id, actor, idempotency_key);
+CREATE INDEX IF NOT EXISTS api_keys_tenant ON public.api_keys (tenant_id, api_key_id);
+
+CREATE OR REPLACE FUNCTION public.audit_change() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+DECLARE
+    old_row jsonb := CASE WHEN TG_OP IN ('UPDATE', 'DELETE') THEN to_jsonb(OLD) END;
+    new_row jsonb := CASE WHEN TG_OP IN ('INSERT', 'UPDATE') THEN to_jsonb(NEW) END;
+BEGIN
+    INSERT INTO public.audit_log(tenant_id,actor,operation,entity,entity_id,before,after)
+    VALUES (
+        COALESCE(new_row, old_row)->>'tenant_id',
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), 'unknown'),
+        lower(TG_OP),
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row)->>TG_ARGV[1])::bigint,
+        old_row,
+        new_row
+    );
+    RETURN NULL;
+END;
+$$;
+
+-- row level security limits every query to the tenant set by the application
+-- for the connection with set_config('app.tenant_id',...) in case a query
+-- misses its tenant condition. The tenant * is only set by background jobs.
+-- api_keys and idempotency_keys are looked up before the tenant is known.
+-- Superusers and roles with BYPASSRLS are not limited
+CREATE OR REPLACE FUNCTION public.tenant_visible(tenant_id character varying) RETURNS boolean
+    LANGUAGE sql STABLE AS $$
+    SELECT current_setting('app.tenant_id', true) IN (tenant_id, '*')
+$$;
+ALTER TABLE public.services ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.services FORCE ROW LEVEL SECURITY;
+CREATE POLICY services_tenant ON public.services USING (public.tenant_visible(tenant_id));
+ALTER TABLE public.subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.subscriptions FORCE ROW LEVEL SECURITY;
+CREATE POLICY subscriptions_tenant ON public.subscriptions USING (public.tenant_visible(tenant_id));
+ALTER TABLE public.subscription_prices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.subscription_prices FORCE ROW LEVEL SECURITY;
+CREATE POLICY subscription_prices_tenant ON public.subscription_prices USING (public.tenant_visible(tenant_id));
+ALTER TABLE public.audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.audit_log FORCE ROW LEVEL SECURITY;
+CREATE POLICY audit_log_tenant ON public.audit_log USING (public.tenant_visible(tenant_id));
\ No newline at end of file
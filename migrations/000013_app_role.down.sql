@@ -0,0 +1,7 @@
+ALTER DEFAULT PRIVILEGES IN SCHEMA public
+    REVOKE USAGE, SELECT ON SEQUENCES FROM subscription_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public
+    REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM subscription_app;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM subscription_app;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM subscription_app;
+REVOKE USAGE ON SCHEMA public FROM subscription_app;
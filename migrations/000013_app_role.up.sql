@@ -0,0 +1,17 @@
+-- the service connects as a member of the role; row level security applies to
+-- it because it is neither a superuser nor the owner of the tables
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'subscription_app') THEN
+        CREATE ROLE subscription_app NOLOGIN NOSUPERUSER NOBYPASSRLS;
+    END IF;
+END
+$$;
+GRANT USAGE ON SCHEMA public TO subscription_app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO subscription_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO subscription_app;
+REVOKE ALL ON public.schema_migrations FROM subscription_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public
+    GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO subscription_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public
+    GRANT USAGE, SELECT ON SEQUENCES TO subscription_app;
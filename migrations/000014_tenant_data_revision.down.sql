@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS subscription_prices_data_revision ON public.subscription_prices;
+CREATE TRIGGER subscription_prices_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.subscription_prices
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
+DROP TRIGGER IF EXISTS subscriptions_data_revision ON public.subscriptions;
+CREATE TRIGGER subscriptions_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.subscriptions
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
+DROP TRIGGER IF EXISTS services_data_revision ON public.services;
+CREATE TRIGGER services_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.services
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_data_revision();
+DROP FUNCTION IF EXISTS public.bump_tenant_data_revision();
+DROP TABLE IF EXISTS public.tenant_data_revision;
@@ -0,0 +1,38 @@
+-- the changes of a tenant bump the revision of the tenant, the changes of the
+-- exchange rates shared by every tenant and of jobs running for every tenant
+-- bump the global revision in data_revision
+CREATE TABLE IF NOT EXISTS public.tenant_data_revision(
+    tenant_id character varying(63) NOT NULL,
+    revision bigint NOT NULL DEFAULT 0,
+    modified_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT tenant_data_revision_pk PRIMARY KEY (tenant_id)
+);
+ALTER TABLE public.tenant_data_revision ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.tenant_data_revision FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_data_revision_tenant ON public.tenant_data_revision USING (public.tenant_visible(tenant_id));
+CREATE OR REPLACE FUNCTION public.bump_tenant_data_revision() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+DECLARE
+    tenant character varying := current_setting('app.tenant_id', true);
+BEGIN
+    IF tenant IS null OR tenant IN ('', '*') THEN
+        UPDATE public.data_revision SET revision=revision+1, modified_at=now() WHERE revision_id=1;
+    ELSE
+        INSERT INTO public.tenant_data_revision AS t (tenant_id, revision) VALUES (tenant, 1)
+        ON CONFLICT (tenant_id) DO UPDATE SET revision=t.revision+1, modified_at=now();
+    END IF;
+    RETURN NULL;
+END;
+$$;
+DROP TRIGGER IF EXISTS services_data_revision ON public.services;
+CREATE TRIGGER services_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.services
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_tenant_data_revision();
+DROP TRIGGER IF EXISTS subscriptions_data_revision ON public.subscriptions;
+CREATE TRIGGER subscriptions_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.subscriptions
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_tenant_data_revision();
+DROP TRIGGER IF EXISTS subscription_prices_data_revision ON public.subscription_prices;
+CREATE TRIGGER subscription_prices_data_revision
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON public.subscription_prices
+    FOR EACH STATEMENT EXECUTE FUNCTION public.bump_tenant_data_revision();
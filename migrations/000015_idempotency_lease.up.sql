@@ -0,0 +1,4 @@
+-- a request holds its key until locked_until, a repeated request takes over
+-- the key of a request that didn't complete in time
+ALTER TABLE public.idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamp with time zone NOT NULL DEFAULT now();
+ALTER TABLE public.idempotency_keys ALTER COLUMN locked_until DROP DEFAULT;
@@ -0,0 +1,2 @@
+-- the headers of a stored response are replayed with it
+ALTER TABLE public.idempotency_keys ADD COLUMN IF NOT EXISTS headers jsonb;